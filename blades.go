@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BladesRoll is a Blades in the Dark dice pool, which
+// counts only its highest die
+type BladesRoll struct {
+	action string
+	pool   int
+	dice   []int
+}
+
+// nobody should have a bigger pool than this, even with assists
+const maxBladesPool = 10
+
+// parseBlades reads the size of the pool, and the action
+// being made, for !blades
+func parseBlades(args []string) (*BladesRoll, error) {
+	b := &BladesRoll{}
+	var action []string
+	found := false
+
+	for _, arg := range args {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(arg), "d"))
+		if err == nil && !found {
+			if n < 0 || n > maxBladesPool {
+				return nil, fmt.Errorf("pool must be 0 to %d dice", maxBladesPool)
+			}
+			b.pool, found = n, true
+			continue
+		}
+		if arg != "#" {
+			action = append(action, strings.TrimPrefix(arg, "#"))
+		}
+	}
+
+	if !found {
+		return nil, errors.New("Missing dice pool. Eg: !blades 3 skirmish")
+	}
+
+	b.action = strings.Join(action, " ")
+	return b, nil
+}
+
+// roll throws the pool. With no dice, two are rolled and
+// the lowest is taken instead.
+func (b *BladesRoll) roll(r *Roller) {
+	n := b.pool
+	if n == 0 {
+		n = 2
+	}
+
+	b.dice = b.dice[:0]
+	for i := 0; i < n; i++ {
+		b.dice = append(b.dice, r.getRoll(6))
+	}
+}
+
+// result is the die that counts, and how many sixes were rolled
+func (b *BladesRoll) result() (int, int) {
+	kept, sixes := b.dice[0], 0
+	for _, d := range b.dice {
+		if d == 6 {
+			sixes++
+		}
+		if (b.pool == 0 && d < kept) || (b.pool > 0 && d > kept) {
+			kept = d
+		}
+	}
+	return kept, sixes
+}
+
+// outcome is a critical on more than one 6, a full success on a 6,
+// a partial success on 4-5 and a bad outcome on 1-3. A zero dice
+// pool can't crit.
+func (b *BladesRoll) outcome(format bool) string {
+	switch kept, sixes := b.result(); {
+	case kept == 6 && sixes > 1 && b.pool > 0:
+		return highlight("critical success", ircGreen, format)
+	case kept == 6:
+		return highlight("full success", ircGreen, format)
+	case kept >= 4:
+		return highlight("partial success", ircOrange, format)
+	}
+	return highlight("bad outcome", ircRed, format)
+}
+
+// diceString lists the pool, with dice that don't count in
+// parentheses. Every 6 counts towards a critical.
+func (b *BladesRoll) diceString() string {
+	kept, sixes := b.result()
+	crit := sixes > 1 && b.pool > 0
+
+	out := make([]string, 0, len(b.dice))
+	counted := false
+	for _, d := range b.dice {
+		if (crit && d == 6) || (!crit && d == kept && !counted) {
+			counted = true
+			out = append(out, strconv.Itoa(d))
+			continue
+		}
+		out = append(out, fmt.Sprintf("(%d)", d))
+	}
+	return "d6:" + strings.Join(out, ",")
+}
+
+// report describes the roll for the channel
+func (b *BladesRoll) report(nick string, format bool) string {
+	what := fmt.Sprintf("%dd", b.pool)
+	if b.action != "" {
+		what = fmt.Sprintf("%s (%dd)", b.action, b.pool)
+	}
+
+	rolled := strings.Replace(strings.TrimPrefix(b.diceString(), "d6:"), ",", " ", -1)
+	return fmt.Sprintf("%s rolls %s: %s,  %s", nick, what, rolled, b.outcome(format))
+}
+
+// historyRow records the roll for the roll log
+func (b *BladesRoll) historyRow(nick, channel string) RollRow {
+	kept, _ := b.result()
+	return RollRow{
+		nick:    strings.ToLower(nick),
+		channel: strings.ToLower(channel),
+		expr:    fmt.Sprintf("blades %d", b.pool),
+		label:   b.action,
+		dice:    b.diceString(),
+		total:   kept,
+		time:    time.Now().UTC(),
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var bladesCases = []struct {
+	args    string
+	faces   []int
+	want    string
+	dice    string
+	total   int
+	wantErr bool
+}{
+	{args: "3", faces: []int{2, 5, 4}, want: "gbmor rolls 3d: (2) 5 (4),  partial success", dice: "d6:(2),5,(4)", total: 5},
+	{args: "2d skirmish", faces: []int{6, 1}, want: "gbmor rolls skirmish (2d): 6 (1),  full success", dice: "d6:6,(1)", total: 6},
+	{args: "4 # wreck", faces: []int{6, 3, 6, 1}, want: "gbmor rolls wreck (4d): 6 (3) 6 (1),  critical success", dice: "d6:6,(3),6,(1)", total: 6},
+	{args: "1", faces: []int{3}, want: "gbmor rolls 1d: 3,  bad outcome", dice: "d6:3", total: 3},
+	{args: "0", faces: []int{5, 4}, want: "gbmor rolls 0d: (5) 4,  partial success", dice: "d6:(5),4", total: 4},
+	{args: "0", faces: []int{6, 6}, want: "gbmor rolls 0d: 6 (6),  full success", dice: "d6:6,(6)", total: 6},
+	{args: "skirmish", wantErr: true},
+	{args: "11", wantErr: true},
+	{args: "-1", wantErr: true},
+}
+
+func Test_BladesRoll(t *testing.T) {
+	for _, tt := range bladesCases {
+		t.Run(tt.args, func(t *testing.T) {
+			b, err := parseBlades(strings.Fields(tt.args))
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got %v", b)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			b.roll(fixedRoller(tt.faces...))
+			if got := b.report("gbmor", false); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+
+			row := b.historyRow("gbmor", "#ttrpg")
+			if row.dice != tt.dice || row.total != tt.total {
+				t.Errorf("Expected %s = %d, got %s = %d", tt.dice, tt.total, row.dice, row.total)
+			}
+		})
+	}
+}
+
+func Test_parseSystemRoll(t *testing.T) {
+	for cmd, args := range map[string]string{
+		"!coc":    "65",
+		"!skill":  "Spot Hidden 65",
+		"!pbta":   "+1",
+		"!blades": "2",
+	} {
+		sr, err := parseSystemRoll(cmd, strings.Fields(args))
+		if err != nil || sr == nil {
+			t.Errorf("Expected %s %s to parse, got %v", cmd, args, err)
+		}
+	}
+
+	if sr, err := parseSystemRoll("!blades", nil); err == nil || sr != nil {
+		t.Errorf("Expected an error, got %v", sr)
+	}
+	if sr, err := parseSystemRoll("!roll", []string{"1d20"}); err != nil || sr != nil {
+		t.Errorf("Expected nothing, got %v, %v", sr, err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Check is a roll made against a target number, like a DC
+type Check struct {
+	dc      int
+	degrees bool
+}
+
+var checkRegex = regexp.MustCompile(`(?i)^(.*?)\s*(?:\bvs\.?|dc)\s*(-?\d+)(\s+pf2)?\s*$`)
+
+// the names of each degree of success, worst first
+var degreeNames = []string{"critical failure", "failure", "success", "critical success"}
+
+// parseCheck splits a trailing "vs 15" or "dc15" off of a roll,
+// with "pf2" after it asking for degrees of success
+func parseCheck(s string) (string, *Check, error) {
+	match := checkRegex.FindStringSubmatch(s)
+	if match == nil {
+		return s, nil, nil
+	}
+
+	dc, err := strconv.Atoi(match[2])
+	if err != nil || dc > maxLiteral || dc < -maxLiteral {
+		return "", nil, fmt.Errorf("unable to parse target number: %s", match[2])
+	}
+
+	return match[1], &Check{dc: dc, degrees: match[3] != ""}, nil
+}
+
+// degree rates the roll from 0 (critical failure) to 3 (critical
+// success). Without degrees of success it's only ever 1 or 2.
+func (c *Check) degree(res *RollResult) int {
+	margin := res.total - c.dc
+	if !c.degrees {
+		if margin >= 0 {
+			return 2
+		}
+		return 1
+	}
+
+	degree := 1
+	switch {
+	case margin >= 10:
+		degree = 3
+	case margin >= 0:
+		degree = 2
+	case margin <= -10:
+		degree = 0
+	}
+
+	// a natural 20 or 1 shifts the result a degree
+	if res.critical() && degree < 3 {
+		degree++
+	}
+	if res.fumbled() && degree > 0 {
+		degree--
+	}
+
+	return degree
+}
+
+// result reports how the roll fared against the target
+func (c *Check) result(res *RollResult) string {
+	margin := res.total - c.dc
+	if margin < 0 {
+		margin = -margin
+	}
+
+	return fmt.Sprintf("vs %d: %s by %d", c.dc, degreeNames[c.degree(res)], margin)
+}
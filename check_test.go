@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+)
+
+var parseCheckCases = []struct {
+	raw     string
+	expr    string
+	dc      int
+	hasDC   bool
+	degrees bool
+	wantErr bool
+}{
+	{raw: "1d20+5", expr: "1d20+5"},
+	{raw: "1d20+5 vs 15", expr: "1d20+5", dc: 15, hasDC: true},
+	{raw: "1d20+5 VS. 15", expr: "1d20+5", dc: 15, hasDC: true},
+	{raw: "1d20+5 dc15", expr: "1d20+5", dc: 15, hasDC: true},
+	{raw: "1d20+5dc15", expr: "1d20+5", dc: 15, hasDC: true},
+	{raw: "1d20+7 dc 20 pf2", expr: "1d20+7", dc: 20, hasDC: true, degrees: true},
+	{raw: "1d20 vs 99999999", wantErr: true},
+}
+
+func Test_parseCheck(t *testing.T) {
+	for _, tt := range parseCheckCases {
+		t.Run(tt.raw, func(t *testing.T) {
+			expr, check, err := parseCheck(tt.raw)
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got nil: %s", tt.raw)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if expr != tt.expr {
+				t.Errorf("Expected expression %s, got %s", tt.expr, expr)
+			}
+			if (check != nil) != tt.hasDC {
+				t.Fatalf("Expected check %v, got %v", tt.hasDC, check)
+			}
+			if check != nil && (check.dc != tt.dc || check.degrees != tt.degrees) {
+				t.Errorf("Expected dc %d (degrees %v), got %+v", tt.dc, tt.degrees, check)
+			}
+		})
+	}
+}
+
+var checkResultCases = []struct {
+	name    string
+	total   int
+	natural int
+	dc      int
+	degrees bool
+	want    string
+}{
+	{name: "success", total: 19, natural: 14, dc: 15, want: "vs 15: success by 4"},
+	{name: "exact", total: 15, natural: 10, dc: 15, want: "vs 15: success by 0"},
+	{name: "failure", total: 12, natural: 7, dc: 15, want: "vs 15: failure by 3"},
+	{name: "nat 20 is only a crit with degrees", total: 25, natural: 20, dc: 30, want: "vs 30: failure by 5"},
+	{name: "pf2 crit success", total: 25, natural: 18, dc: 15, degrees: true, want: "vs 15: critical success by 10"},
+	{name: "pf2 crit failure", total: 5, natural: 2, dc: 15, degrees: true, want: "vs 15: critical failure by 10"},
+	{name: "pf2 nat 20 shifts up", total: 27, natural: 20, dc: 30, degrees: true, want: "vs 30: success by 3"},
+	{name: "pf2 nat 1 shifts down", total: 16, natural: 1, dc: 15, degrees: true, want: "vs 15: failure by 1"},
+	{name: "pf2 nat 20 caps at crit", total: 30, natural: 20, dc: 15, degrees: true, want: "vs 15: critical success by 15"},
+	{name: "pf2 nat 1 floors at crit fail", total: 3, natural: 1, dc: 15, degrees: true, want: "vs 15: critical failure by 12"},
+}
+
+func Test_checkResult(t *testing.T) {
+	for _, tt := range checkResultCases {
+		t.Run(tt.name, func(t *testing.T) {
+			term := TermResult{sides: 20, dice: []dieRoll{{raw: tt.natural, face: tt.natural}}}
+			switch tt.natural {
+			case 20:
+				term.crits = []int{20}
+			case 1:
+				term.fumbles = []int{1}
+			}
+			res := &RollResult{terms: []TermResult{term}, total: tt.total}
+
+			check := &Check{dc: tt.dc, degrees: tt.degrees}
+			if got := check.result(res); got != tt.want {
+				t.Errorf("Expected '%s', got '%s'", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CocCheck is a Call of Cthulhu 7e percentile skill check
+type CocCheck struct {
+	skill      string
+	value      int
+	bonus      int
+	difficulty int
+	tens       []int
+	units      int
+	result     int
+}
+
+// the levels of a CoC check, worst first
+const (
+	cocFumble = iota
+	cocFailure
+	cocRegular
+	cocHard
+	cocExtreme
+	cocCritical
+)
+
+var cocLevelNames = []string{"fumble", "failure", "regular success", "hard success", "extreme success", "critical success"}
+
+// no more than two bonus or penalty dice are ever rolled
+const (
+	maxCocBonus = 2
+	maxCocSkill = 200
+)
+
+var cocDiceRegex = regexp.MustCompile(`(?i)^(?:([+-])(\d)|(b|bonus|p|penalty)(\d)?)$`)
+
+// parseCoc reads the skill and its value for !coc or !skill, along
+// with any bonus or penalty dice ("+1", "b", "p2", "penalty") and
+// the difficulty ("hard" or "extreme") of the check
+func parseCoc(args []string) (*CocCheck, error) {
+	c := &CocCheck{difficulty: cocRegular}
+	var name []string
+
+	for _, arg := range args {
+		if n, err := strconv.Atoi(arg); err == nil && !strings.ContainsAny(arg, "+-") {
+			if n < 1 || n > maxCocSkill {
+				return nil, fmt.Errorf("skill must be 1 to %d", maxCocSkill)
+			}
+			c.value = n
+			continue
+		}
+
+		if match := cocDiceRegex.FindStringSubmatch(arg); match != nil {
+			n, sign := 1, 1
+			switch {
+			case match[1] != "":
+				n, _ = strconv.Atoi(match[2])
+				if match[1] == "-" {
+					sign = -1
+				}
+			case match[4] != "":
+				n, _ = strconv.Atoi(match[4])
+			}
+			if strings.HasPrefix(strings.ToLower(match[3]), "p") {
+				sign = -1
+			}
+			c.bonus += sign * n
+			continue
+		}
+
+		switch strings.ToLower(arg) {
+		case "hard":
+			c.difficulty = cocHard
+		case "extreme":
+			c.difficulty = cocExtreme
+		default:
+			name = append(name, arg)
+		}
+	}
+
+	if c.value == 0 {
+		return nil, errors.New("Missing skill value. Eg: !skill Spot Hidden 65")
+	}
+	if c.bonus > maxCocBonus || c.bonus < -maxCocBonus {
+		return nil, fmt.Errorf("no more than %d bonus or penalty dice", maxCocBonus)
+	}
+
+	c.skill = strings.Join(name, " ")
+	return c, nil
+}
+
+// roll throws the units die and a tens die for each bonus or penalty
+// die, then keeps the lowest result for bonus dice or the highest for
+// penalty dice. 00 and 0 make 100.
+func (c *CocCheck) roll(r *Roller) {
+	c.units = r.getRoll(10) - 1
+
+	dice := 1 + c.bonus
+	if c.bonus < 0 {
+		dice = 1 - c.bonus
+	}
+
+	c.tens = c.tens[:0]
+	for i := 0; i < dice; i++ {
+		c.tens = append(c.tens, r.getRoll(10)-1)
+	}
+
+	c.result = 0
+	for _, n := range c.results() {
+		if c.result == 0 || (c.bonus > 0 && n < c.result) || (c.bonus < 0 && n > c.result) {
+			c.result = n
+		}
+	}
+}
+
+// results is the percentile each tens die makes with the units die
+func (c *CocCheck) results() []int {
+	out := make([]int, 0, len(c.tens))
+	for _, t := range c.tens {
+		n := t*10 + c.units
+		if n == 0 {
+			n = 100
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// level rates the roll. A 01 is a critical, and a 100 is a fumble,
+// as is anything from 96 up when the skill is below 50.
+func (c *CocCheck) level() int {
+	switch {
+	case c.result == 1:
+		return cocCritical
+	case c.result == 100, c.value < 50 && c.result >= 96:
+		return cocFumble
+	case c.result <= c.value/5:
+		return cocExtreme
+	case c.result <= c.value/2:
+		return cocHard
+	case c.result <= c.value:
+		return cocRegular
+	}
+	return cocFailure
+}
+
+// diceString records the percentiles rolled for the roll log,
+// with those not kept in parentheses
+func (c *CocCheck) diceString() string {
+	out := make([]string, 0, len(c.tens))
+	kept := false
+	for _, n := range c.results() {
+		if n == c.result && !kept {
+			kept = true
+			out = append(out, strconv.Itoa(n))
+			continue
+		}
+		out = append(out, fmt.Sprintf("(%d)", n))
+	}
+	return "d100:" + strings.Join(out, ",")
+}
+
+// String describes the check without who made it
+func (c *CocCheck) String() string {
+	what := fmt.Sprintf("%d", c.value)
+	if c.skill != "" {
+		what = fmt.Sprintf("%s (%d)", c.skill, c.value)
+	}
+	switch c.difficulty {
+	case cocHard:
+		what += " hard"
+	case cocExtreme:
+		what += " extreme"
+	}
+
+	switch {
+	case c.bonus == 1:
+		what += " with 1 bonus die"
+	case c.bonus > 1:
+		what += fmt.Sprintf(" with %d bonus dice", c.bonus)
+	case c.bonus == -1:
+		what += " with 1 penalty die"
+	case c.bonus < -1:
+		what += fmt.Sprintf(" with %d penalty dice", -c.bonus)
+	}
+	return what
+}
+
+// report describes the check for the channel
+func (c *CocCheck) report(nick string, format bool) string {
+	rolled := strings.TrimPrefix(c.diceString(), "d100:")
+	rolled = strings.Replace(rolled, ",", " ", -1)
+
+	level := c.level()
+	outcome := cocLevelNames[level]
+	if level > cocFailure && level < c.difficulty {
+		outcome += fmt.Sprintf(", not enough for %s", strings.TrimSuffix(cocLevelNames[c.difficulty], " success"))
+	}
+
+	color := ircGreen
+	if level < c.difficulty {
+		color = ircRed
+	}
+
+	return fmt.Sprintf("%s rolls %s: %s,  %s", nick, c, rolled, highlight(outcome, color, format))
+}
+
+// historyRow records the check for the roll log, as it would be
+// given to !coc
+func (c *CocCheck) historyRow(nick, channel string) RollRow {
+	expr := fmt.Sprintf("coc %d", c.value)
+	switch c.difficulty {
+	case cocHard:
+		expr += " hard"
+	case cocExtreme:
+		expr += " extreme"
+	}
+	switch {
+	case c.bonus > 0:
+		expr += fmt.Sprintf(" b%d", c.bonus)
+	case c.bonus < 0:
+		expr += fmt.Sprintf(" p%d", -c.bonus)
+	}
+
+	return RollRow{
+		nick:    strings.ToLower(nick),
+		channel: strings.ToLower(channel),
+		expr:    expr,
+		label:   c.skill,
+		dice:    c.diceString(),
+		total:   c.result,
+		time:    time.Now().UTC(),
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var parseCocCases = []struct {
+	args    string
+	want    *CocCheck
+	wantErr bool
+}{
+	{args: "65", want: &CocCheck{value: 65, difficulty: cocRegular}},
+	{args: "Spot Hidden 65", want: &CocCheck{skill: "Spot Hidden", value: 65, difficulty: cocRegular}},
+	{args: "Spot Hidden 65 +1", want: &CocCheck{skill: "Spot Hidden", value: 65, bonus: 1, difficulty: cocRegular}},
+	{args: "Dodge 40 p2 hard", want: &CocCheck{skill: "Dodge", value: 40, bonus: -2, difficulty: cocHard}},
+	{args: "50 bonus penalty b", want: &CocCheck{value: 50, bonus: 1, difficulty: cocRegular}},
+	{args: "Library Use -1 70 extreme", want: &CocCheck{skill: "Library Use", value: 70, bonus: -1, difficulty: cocExtreme}},
+	{args: "Spot Hidden", wantErr: true},
+	{args: "65 +3", wantErr: true},
+	{args: "0", wantErr: true},
+	{args: "201", wantErr: true},
+}
+
+func Test_parseCoc(t *testing.T) {
+	for _, tt := range parseCocCases {
+		t.Run(tt.args, func(t *testing.T) {
+			got, err := parseCoc(strings.Fields(tt.args))
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got %v", got)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+var cocRollCases = []struct {
+	name   string
+	value  int
+	bonus  int
+	faces  []int
+	result int
+	level  int
+	dice   string
+}{
+	// the units die is rolled first, then the tens dice
+	{name: "regular", value: 65, faces: []int{4, 6}, result: 53, level: cocRegular, dice: "d100:53"},
+	{name: "hard", value: 65, faces: []int{3, 4}, result: 32, level: cocHard, dice: "d100:32"},
+	{name: "extreme", value: 65, faces: []int{2, 2}, result: 11, level: cocExtreme, dice: "d100:11"},
+	{name: "critical", value: 65, faces: []int{2, 1}, result: 1, level: cocCritical, dice: "d100:1"},
+	{name: "failure", value: 65, faces: []int{1, 8}, result: 70, level: cocFailure, dice: "d100:70"},
+	{name: "100", value: 65, faces: []int{1, 1}, result: 100, level: cocFumble, dice: "d100:100"},
+	{name: "fumble under 50", value: 40, faces: []int{7, 10}, result: 96, level: cocFumble, dice: "d100:96"},
+	{name: "no fumble from 50", value: 50, faces: []int{7, 10}, result: 96, level: cocFailure, dice: "d100:96"},
+	{name: "bonus", value: 65, bonus: 1, faces: []int{4, 8, 2}, result: 13, level: cocExtreme, dice: "d100:(73),13"},
+	{name: "penalty", value: 65, bonus: -2, faces: []int{4, 2, 8, 5}, result: 73, level: cocFailure, dice: "d100:(13),73,(43)"},
+	{name: "bonus 00", value: 65, bonus: 1, faces: []int{1, 1, 5}, result: 40, level: cocRegular, dice: "d100:(100),40"},
+}
+
+func Test_CocCheck_roll(t *testing.T) {
+	for _, tt := range cocRollCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &CocCheck{value: tt.value, bonus: tt.bonus, difficulty: cocRegular}
+			c.roll(fixedRoller(tt.faces...))
+
+			if c.result != tt.result {
+				t.Errorf("Expected %d, got %d", tt.result, c.result)
+			}
+			if c.level() != tt.level {
+				t.Errorf("Expected %s, got %s", cocLevelNames[tt.level], cocLevelNames[c.level()])
+			}
+			if c.diceString() != tt.dice {
+				t.Errorf("Expected %s, got %s", tt.dice, c.diceString())
+			}
+		})
+	}
+}
+
+func Test_CocCheck_report(t *testing.T) {
+	c, err := parseCoc(strings.Fields("Spot Hidden 65 +1 extreme"))
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+	c.roll(fixedRoller(4, 8, 3))
+
+	want := "gbmor rolls Spot Hidden (65) extreme with 1 bonus die: (73) 23,  hard success, not enough for extreme"
+	if got := c.report("gbmor", false); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	row := c.historyRow("GBMor", "#TTRPG")
+	if row.expr != "coc 65 extreme b1" || row.label != "Spot Hidden" || row.dice != "d100:(73),23" || row.total != 23 || row.nick != "gbmor" {
+		t.Errorf("Unexpected history row: %v", row)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+)
+
+// CritConf controls how critical hits and fumbles are reported
+type CritConf struct {
+	format  bool
+	hits    []string
+	fumbles []string
+}
+
+// tableResult picks an entry from the critical hit or fumble
+// table for the roll, if it crit or fumbled and a table is set
+func (c CritConf) tableResult(res *RollResult, r *Roller) string {
+	var out string
+
+	if res.critical() && len(c.hits) > 0 {
+		out += fmt.Sprintf("Critical hit: %s", c.hits[r.getRoll(len(c.hits))-1])
+	}
+	if res.fumbled() && len(c.fumbles) > 0 {
+		if out != "" {
+			out += "  "
+		}
+		out += fmt.Sprintf("Fumble: %s", c.fumbles[r.getRoll(len(c.fumbles))-1])
+	}
+
+	return out
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -38,6 +39,29 @@ type NPCRow struct {
 	notes string
 }
 
+// RollRow holds a given row from table rolls
+type RollRow struct {
+	nick    string
+	channel string
+	expr    string
+	label   string
+	dice    string
+	total   int
+	secret  bool
+	time    time.Time
+	session int
+	seq     int
+}
+
+// String describes the roll for !history
+func (row RollRow) String() string {
+	what := row.expr
+	if row.label != "" {
+		what = fmt.Sprintf("%s (%s)", row.label, row.expr)
+	}
+	return fmt.Sprintf("%s %s rolled %s: %s = %d", row.time.Format("2006-01-02 15:04"), row.nick, what, row.dice, row.total)
+}
+
 // MonsterRow holds a given row from table monsters
 type MonsterRow struct {
 	name  string
@@ -110,6 +134,45 @@ func (db *DB) init(path string) error {
 		return fmt.Errorf("Couldn't create-if-not-exists table `monsters`: %w", err)
 	}
 
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS rolls (
+		nick TEXT NOT NULL,
+		channel TEXT NOT NULL,
+		expr TEXT NOT NULL,
+		label TEXT,
+		dice TEXT NOT NULL,
+		total INTEGER NOT NULL,
+		secret INTEGER NOT NULL,
+		time INTEGER NOT NULL,
+		session INTEGER NOT NULL DEFAULT 0,
+		seq INTEGER NOT NULL DEFAULT 0
+	);`)
+	if err != nil {
+		return fmt.Errorf("Couldn't create-if-not-exists table `rolls`: %w", err)
+	}
+
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS seals (
+		id INTEGER PRIMARY KEY,
+		channel TEXT NOT NULL,
+		seed TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		revealed INTEGER NOT NULL,
+		time INTEGER NOT NULL
+	);`)
+	if err != nil {
+		return fmt.Errorf("Couldn't create-if-not-exists table `seals`: %w", err)
+	}
+
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS macros (
+		nick TEXT NOT NULL,
+		campaign TEXT NOT NULL,
+		name TEXT NOT NULL,
+		body TEXT NOT NULL,
+		PRIMARY KEY (nick, campaign, name)
+	);`)
+	if err != nil {
+		return fmt.Errorf("Couldn't create-if-not-exists table `macros`: %w", err)
+	}
+
 	return nil
 }
 
@@ -267,3 +330,280 @@ func (db *DB) addCampaignUser(name, requser, user string) error {
 
 	return nil
 }
+
+func (db *DB) logRolls(rows []RollRow) error {
+	if err := db.conn.Ping(); err != nil {
+		return fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("Couldn't begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err := tx.Commit(); err != nil {
+			log.Printf("%s", err.Error())
+			tx.Rollback()
+		}
+	}()
+
+	for _, row := range rows {
+		_, err = tx.Exec("INSERT INTO rolls (nick, channel, expr, label, dice, total, secret, time, session, seq) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			row.nick, row.channel, row.expr, row.label, row.dice, row.total, row.secret, row.time.Unix(), row.session, row.seq)
+		if err != nil {
+			return fmt.Errorf("Couldn't execute statement: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// getRolls returns the most recent public rolls made in a channel,
+// newest first, optionally only those made by nick
+func (db *DB) getRolls(channel, nick string, limit int) ([]RollRow, error) {
+	if err := db.conn.Ping(); err != nil {
+		return nil, fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	rows, err := db.conn.Query(`SELECT nick, channel, expr, label, dice, total, secret, time FROM rolls
+		WHERE channel=:channel AND (:nick='' OR nick=:nick) AND secret=0
+		ORDER BY time DESC, rowid DESC LIMIT :limit`,
+		sql.Named("channel", channel), sql.Named("nick", nick), sql.Named("limit", limit))
+	if err != nil {
+		return nil, fmt.Errorf("Querying rolls: %w", err)
+	}
+	defer rows.Close()
+
+	var out []RollRow
+	for rows.Next() {
+		row := RollRow{}
+		var stamp int64
+		if err := rows.Scan(&row.nick, &row.channel, &row.expr, &row.label, &row.dice, &row.total, &row.secret, &stamp); err != nil {
+			return nil, fmt.Errorf("Scanning rolls: %w", err)
+		}
+		row.time = time.Unix(stamp, 0).UTC()
+		out = append(out, row)
+	}
+
+	return out, rows.Err()
+}
+
+// getRollDice returns the dice from every public roll,
+// optionally only those made by nick. Secret rolls are left
+// out so their faces can't be worked out from the stats.
+func (db *DB) getRollDice(nick string) ([]string, error) {
+	if err := db.conn.Ping(); err != nil {
+		return nil, fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	rows, err := db.conn.Query("SELECT dice FROM rolls WHERE (:nick='' OR nick=:nick) AND secret=0", sql.Named("nick", nick))
+	if err != nil {
+		return nil, fmt.Errorf("Querying roll dice: %w", err)
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var dice string
+		if err := rows.Scan(&dice); err != nil {
+			return nil, fmt.Errorf("Scanning roll dice: %w", err)
+		}
+		out = append(out, dice)
+	}
+
+	return out, rows.Err()
+}
+
+func (db *DB) getCampaignUsers(campaign string) ([]string, error) {
+	if err := db.conn.Ping(); err != nil {
+		return nil, fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	row := db.conn.QueryRow("SELECT * FROM campaigns WHERE name=:campaign", sql.Named("campaign", campaign))
+
+	crow := CampaignRow{}
+	if err := row.Scan(&crow.name, &crow.users, &crow.notes); err != nil {
+		return nil, fmt.Errorf("Querying campaign users: %w", err)
+	}
+
+	return strings.Fields(crow.users), nil
+}
+
+// addSeal stores a new seal, filling in its id
+func (db *DB) addSeal(seal *Seal) error {
+	if err := db.conn.Ping(); err != nil {
+		return fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	res, err := db.conn.Exec("INSERT INTO seals (channel, seed, hash, revealed, time) VALUES(?, ?, ?, ?, ?)",
+		seal.channel, seal.seed, seal.hash, seal.revealed, seal.time.Unix())
+	if err != nil {
+		return fmt.Errorf("Couldn't add seal: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	seal.id = int(id)
+	return err
+}
+
+// getSeal returns a seal by id, or if id is 0 the newest seal in the
+// channel that's open or, if revealed is true, has been revealed.
+// It returns nil if there isn't one.
+func (db *DB) getSeal(channel string, id int, revealed bool) (*Seal, error) {
+	if err := db.conn.Ping(); err != nil {
+		return nil, fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	row := db.conn.QueryRow(`SELECT id, channel, seed, hash, revealed, time FROM seals
+		WHERE (:id=0 AND channel=:channel AND revealed=:revealed) OR id=:id
+		ORDER BY id DESC LIMIT 1`,
+		sql.Named("channel", strings.ToLower(channel)), sql.Named("id", id), sql.Named("revealed", revealed))
+
+	seal := &Seal{}
+	var stamp int64
+	err := row.Scan(&seal.id, &seal.channel, &seal.seed, &seal.hash, &seal.revealed, &stamp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Querying seal: %w", err)
+	}
+
+	seal.time = time.Unix(stamp, 0).UTC()
+	return seal, nil
+}
+
+func (db *DB) revealSeal(id int) error {
+	if err := db.conn.Ping(); err != nil {
+		return fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	if _, err := db.conn.Exec("UPDATE seals SET revealed=1 WHERE id=?", id); err != nil {
+		return fmt.Errorf("Couldn't reveal seal: %w", err)
+	}
+	return nil
+}
+
+// nextSeq numbers the next roll command made under a seal
+func (db *DB) nextSeq(session int) (int, error) {
+	if err := db.conn.Ping(); err != nil {
+		return 0, fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	var seq int
+	row := db.conn.QueryRow("SELECT COALESCE(MAX(seq), 0) + 1 FROM rolls WHERE session=?", session)
+	if err := row.Scan(&seq); err != nil {
+		return 0, fmt.Errorf("Querying roll sequence: %w", err)
+	}
+	return seq, nil
+}
+
+// getSealRolls returns every roll made under a seal, in the order made
+func (db *DB) getSealRolls(session int) ([]RollRow, error) {
+	if err := db.conn.Ping(); err != nil {
+		return nil, fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	rows, err := db.conn.Query(`SELECT nick, channel, expr, label, dice, total, secret, time, session, seq FROM rolls
+		WHERE session=? ORDER BY seq, rowid`, session)
+	if err != nil {
+		return nil, fmt.Errorf("Querying sealed rolls: %w", err)
+	}
+	defer rows.Close()
+
+	var out []RollRow
+	for rows.Next() {
+		row := RollRow{}
+		var stamp int64
+		if err := rows.Scan(&row.nick, &row.channel, &row.expr, &row.label, &row.dice, &row.total, &row.secret, &stamp, &row.session, &row.seq); err != nil {
+			return nil, fmt.Errorf("Scanning sealed rolls: %w", err)
+		}
+		row.time = time.Unix(stamp, 0).UTC()
+		out = append(out, row)
+	}
+
+	return out, rows.Err()
+}
+
+// setMacro saves a macro, replacing any of the same name
+func (db *DB) setMacro(m *Macro) error {
+	if err := db.conn.Ping(); err != nil {
+		return fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	_, err := db.conn.Exec("INSERT OR REPLACE INTO macros (nick, campaign, name, body) VALUES(?, ?, ?, ?)",
+		m.nick, m.campaign, m.name, m.body)
+	if err != nil {
+		return fmt.Errorf("Couldn't set macro: %w", err)
+	}
+	return nil
+}
+
+// delMacro deletes a macro, reporting whether there was one
+func (db *DB) delMacro(nick, campaign, name string) (bool, error) {
+	if err := db.conn.Ping(); err != nil {
+		return false, fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	res, err := db.conn.Exec("DELETE FROM macros WHERE nick=? AND campaign=? AND name=?", nick, campaign, name)
+	if err != nil {
+		return false, fmt.Errorf("Couldn't delete macro: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// getMacros returns a nick's own macros along with any
+// they've made for the given campaign, by name
+func (db *DB) getMacros(nick, campaign string) ([]Macro, error) {
+	if err := db.conn.Ping(); err != nil {
+		return nil, fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	rows, err := db.conn.Query(`SELECT nick, campaign, name, body FROM macros
+		WHERE nick=:nick AND (campaign='' OR campaign=:campaign) ORDER BY name, campaign`,
+		sql.Named("nick", nick), sql.Named("campaign", campaign))
+	if err != nil {
+		return nil, fmt.Errorf("Querying macros: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Macro
+	for rows.Next() {
+		m := Macro{}
+		if err := rows.Scan(&m.nick, &m.campaign, &m.name, &m.body); err != nil {
+			return nil, fmt.Errorf("Scanning macros: %w", err)
+		}
+		out = append(out, m)
+	}
+
+	return out, rows.Err()
+}
+
+// getPC returns a user's character sheet for a campaign, or if campaign
+// is empty their newest one. It returns nil if they don't have one.
+func (db *DB) getPC(user, campaign string) (*PCRow, error) {
+	if err := db.conn.Ping(); err != nil {
+		return nil, fmt.Errorf("Couldn't ping database: %w", err)
+	}
+
+	row := db.conn.QueryRow(`SELECT user, campaign, char, notes FROM pcs
+		WHERE user=:user AND (:campaign='' OR campaign=:campaign)
+		ORDER BY rowid DESC LIMIT 1`,
+		sql.Named("user", user), sql.Named("campaign", campaign))
+
+	pc := &PCRow{}
+	var notes sql.NullString
+	err := row.Scan(&pc.user, &pc.campaign, &pc.char, &notes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Querying character sheet: %w", err)
+	}
+
+	pc.notes = notes.String
+	return pc, nil
+}
@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 const testDBLocation = ":memory:"
@@ -154,3 +155,236 @@ func Test_addCampaignuser(t *testing.T) {
 		}
 	})
 }
+
+func Test_logRolls(t *testing.T) {
+	t.Run("log and retrieve rolls", func(t *testing.T) {
+		db := initDB(testDBLocation)
+		defer uninitDB(db)
+
+		then := time.Date(2020, 2, 13, 20, 0, 0, 0, time.UTC)
+		rows := []RollRow{
+			{nick: "gbmor", channel: "#ttrpg", expr: "1d20+2", label: "initiative", dice: "d20:14", total: 16, time: then},
+			{nick: "somenerd", channel: "#ttrpg", expr: "2d6", dice: "d6:3,4", total: 7, time: then.Add(time.Minute)},
+			{nick: "gbmor", channel: "#ttrpg", expr: "1d20", dice: "d20:1", total: 1, secret: true, time: then.Add(2 * time.Minute)},
+			{nick: "gbmor", channel: "#elsewhere", expr: "1d4", dice: "d4:2", total: 2, time: then.Add(3 * time.Minute)},
+		}
+		if err := db.logRolls(rows); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		out, err := db.getRolls("#ttrpg", "", 5)
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		if len(out) != 2 || out[0].nick != "somenerd" || out[1].nick != "gbmor" {
+			t.Errorf("Expected public #ttrpg rolls newest first, got %v", out)
+		}
+
+		out, err = db.getRolls("#ttrpg", "gbmor", 5)
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		if len(out) != 1 || !reflect.DeepEqual(out[0], rows[0]) {
+			t.Errorf("Expected %v, got %v", rows[0], out)
+		}
+
+		want := "2020-02-13 20:00 gbmor rolled initiative (1d20+2): d20:14 = 16"
+		if out[0].String() != want {
+			t.Errorf("Expected '%s', got '%s'", want, out[0].String())
+		}
+
+		out, err = db.getRolls("#ttrpg", "", 1)
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		if len(out) != 1 {
+			t.Errorf("Expected 1 roll, got %d", len(out))
+		}
+	})
+}
+
+func Test_getRollDice(t *testing.T) {
+	t.Run("get roll dice and campaign users", func(t *testing.T) {
+		db := initDB(testDBLocation)
+		defer uninitDB(db)
+
+		now := time.Now()
+		rows := []RollRow{
+			{nick: "gbmor", channel: "#ttrpg", expr: "1d20", dice: "d20:14", total: 14, time: now},
+			{nick: "somenerd", channel: "#ttrpg", expr: "2d6", dice: "d6:3,4", total: 7, time: now},
+			{nick: "gbmor", channel: "#ttrpg", expr: "1d20", dice: "d20:3", total: 3, secret: true, time: now},
+		}
+		if err := db.logRolls(rows); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		out, err := db.getRollDice("gbmor")
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		if !reflect.DeepEqual(out, []string{"d20:14"}) {
+			t.Errorf("Expected [d20:14], got %v", out)
+		}
+
+		out, err = db.getRollDice("")
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		if len(out) != 2 {
+			t.Errorf("Expected every roll, got %v", out)
+		}
+
+		if err := db.createCampaign("gronkulousness", "gbmor"); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		if err := db.addCampaignUser("gronkulousness", "gbmor", "somenerd"); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		users, err := db.getCampaignUsers("gronkulousness")
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		if !reflect.DeepEqual(users, []string{"gbmor", "somenerd"}) {
+			t.Errorf("Expected [gbmor somenerd], got %v", users)
+		}
+		if _, err := db.getCampaignUsers("nope"); err == nil {
+			t.Error("Expected error for a missing campaign")
+		}
+	})
+}
+
+func Test_seals(t *testing.T) {
+	t.Run("add, reveal and retrieve seals", func(t *testing.T) {
+		db := initDB(testDBLocation)
+		defer uninitDB(db)
+
+		seal, err := newSeal("#TTRPG")
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		if err := db.addSeal(seal); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		open, err := db.getSeal("#ttrpg", 0, false)
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		if open == nil || open.id != seal.id || open.hash != seal.hash || open.revealed {
+			t.Fatalf("Expected open seal %v, got %v", seal, open)
+		}
+
+		r, got, seq := db.sealedRoller("#ttrpg", nil)
+		if got == nil || seq != 1 || r == nil {
+			t.Fatalf("Expected the first roll under seal %d, got %v seq %d", seal.id, got, seq)
+		}
+		rows := []RollRow{{nick: "gbmor", channel: "#ttrpg", expr: "1d20", dice: "d20:14", total: 14, time: time.Now()}}
+		sealRows(rows, got, seq)
+		if err := db.logRolls(rows); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		if _, _, seq = db.sealedRoller("#ttrpg", nil); seq != 2 {
+			t.Errorf("Expected the second roll under the seal, got %d", seq)
+		}
+
+		if err := db.revealSeal(seal.id); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		if open, err = db.getSeal("#ttrpg", 0, false); err != nil || open != nil {
+			t.Errorf("Expected no open seal, got %v, %v", open, err)
+		}
+		if r, got, _ = db.sealedRoller("#ttrpg", nil); r != nil || got != nil {
+			t.Errorf("Expected the fallback roller once revealed")
+		}
+
+		revealed, err := db.getSeal("#elsewhere", seal.id, true)
+		if err != nil || revealed == nil || !revealed.revealed || revealed.seed != seal.seed {
+			t.Errorf("Expected revealed seal %d, got %v, %v", seal.id, revealed, err)
+		}
+
+		out, err := db.getSealRolls(seal.id)
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		if len(out) != 1 || out[0].session != seal.id || out[0].seq != 1 {
+			t.Errorf("Expected the sealed roll, got %v", out)
+		}
+	})
+}
+
+func Test_macros(t *testing.T) {
+	t.Run("set, get and delete macros", func(t *testing.T) {
+		db := initDB(testDBLocation)
+		defer uninitDB(db)
+
+		for _, m := range []*Macro{
+			{nick: "gbmor", name: "attack", body: "1d20+7"},
+			{nick: "gbmor", campaign: "gronk", name: "attack", body: "1d20+5"},
+			{nick: "gbmor", campaign: "other", name: "damage", body: "1d6"},
+			{nick: "somenerd", name: "attack", body: "1d20"},
+			{nick: "gbmor", name: "attack", body: "1d20+8"},
+		} {
+			if err := db.setMacro(m); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+		}
+
+		out, err := db.getMacros("gbmor", "gronk")
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		want := []Macro{
+			{nick: "gbmor", name: "attack", body: "1d20+8"},
+			{nick: "gbmor", campaign: "gronk", name: "attack", body: "1d20+5"},
+		}
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("Expected %v, got %v", want, out)
+		}
+
+		found, err := db.delMacro("gbmor", "", "attack")
+		if err != nil || !found {
+			t.Fatalf("Expected to delete attack, got %v, %v", found, err)
+		}
+		if found, _ = db.delMacro("gbmor", "", "attack"); found {
+			t.Errorf("Expected attack to be gone")
+		}
+
+		if out, _ = db.getMacros("gbmor", ""); len(out) != 0 {
+			t.Errorf("Expected no macros, got %v", out)
+		}
+	})
+}
+
+func Test_getPC(t *testing.T) {
+	t.Run("get character sheets", func(t *testing.T) {
+		db := initDB(testDBLocation)
+		defer uninitDB(db)
+
+		for _, pc := range []PCRow{
+			{user: "gbmor", campaign: "gronk", char: "Kira", notes: "momentum: 4"},
+			{user: "gbmor", campaign: "other", char: "Ash", notes: "momentum: 2"},
+		} {
+			_, err := db.conn.Exec("INSERT INTO pcs (user, campaign, char, notes) VALUES(?, ?, ?, ?)", pc.user, pc.campaign, pc.char, pc.notes)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+		}
+		if _, err := db.conn.Exec("INSERT INTO pcs (user, campaign, char) VALUES('somenerd', 'gronk', 'Vex')"); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		pc, err := db.getPC("gbmor", "gronk")
+		if err != nil || pc == nil || pc.char != "Kira" {
+			t.Errorf("Expected Kira, got %v, %v", pc, err)
+		}
+		if pc, err = db.getPC("gbmor", ""); err != nil || pc == nil || pc.char != "Ash" {
+			t.Errorf("Expected the newest sheet, got %v, %v", pc, err)
+		}
+		if pc, err = db.getPC("somenerd", "gronk"); err != nil || pc == nil || pc.notes != "" {
+			t.Errorf("Expected a sheet without notes, got %v, %v", pc, err)
+		}
+		if pc, err = db.getPC("nobody", ""); err != nil || pc != nil {
+			t.Errorf("Expected no sheet, got %v, %v", pc, err)
+		}
+	})
+}
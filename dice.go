@@ -3,77 +3,506 @@ package main
 import (
 	"errors"
 	"fmt"
-	"math/rand"
-	"strconv"
+	"sort"
 	"strings"
 )
 
-var validDice []int = []int{4, 6, 8, 10, 12, 20, 100}
+// DiceLimits bounds the dice !roll will accept
+type DiceLimits struct {
+	allowed  []int
+	maxSides int
+	maxDice  int
+}
+
+// any die up to a d1000, and no more than 100 dice per roll,
+// unless dungeonbot.yml says otherwise
+var defaultDiceLimits = DiceLimits{
+	maxSides: 1000,
+	maxDice:  100,
+}
 
-// [0,n] exclusive on the upper bound
-func getRoll(ceiling int) int {
-	out := rand.Intn(ceiling)
-	return out + 1
+// describe explains the limits for the help text
+func (l DiceLimits) describe() string {
+	sides := fmt.Sprintf("Dice may have up to %d sides", l.maxSides)
+	if len(l.allowed) > 0 {
+		kinds := make([]string, 0, len(l.allowed))
+		for _, kind := range l.allowed {
+			kinds = append(kinds, fmt.Sprintf("d%d", kind))
+		}
+		sides = fmt.Sprintf("The die type must be one of [%s]", strings.Join(kinds, "|"))
+	}
+	return fmt.Sprintf("%s and no more than %d may be rolled at once.", sides, l.maxDice)
+}
+
+// validSides reports whether a die with n sides may be rolled
+func (l DiceLimits) validSides(n int) bool {
+	if n < 1 || n > l.maxSides {
+		return false
+	}
+	if len(l.allowed) == 0 {
+		return true
+	}
+	for _, kind := range l.allowed {
+		if n == kind {
+			return true
+		}
+	}
+	return false
 }
 
-func parseDice(s string) (string, error) {
-	split := strings.Split(s, "d")
-	plus := 0
+var fudgeFaces = []string{"[-]", "[ ]", "[+]"}
 
-	plusRaw := strings.Split(split[1], "+")
-	if plusRaw[0] == "69" {
-		return "n i c e", nil
+// the FATE ladder, from Terrible (-2) up to Legendary (+8)
+var fateAdjectives = []string{
+	"Terrible", "Poor", "Mediocre", "Average", "Fair",
+	"Good", "Great", "Superb", "Fantastic", "Epic", "Legendary",
+}
+
+// fateLadder names a FATE result
+func fateLadder(n int) string {
+	switch {
+	case n < -2:
+		return "below Terrible"
+	case n > 8:
+		return "beyond Legendary"
 	}
+	return fateAdjectives[n+2]
+}
 
-	plus, err := strconv.Atoi(plusRaw[len(plusRaw)-1])
-	if err != nil || len(plusRaw) == 1 {
-		plus = 0
+// parseDice rolls a dice expression and renders the result for IRC
+func (r *Roller) parseDice(s string, limits DiceLimits) (string, error) {
+	res, err := r.roll(s, limits)
+	if err != nil {
+		return "", err
 	}
+	return res.String(), nil
+}
 
-	diceNum, err := strconv.Atoi(split[0])
+// roll parses and rolls a dice expression within the given limits
+func (r *Roller) roll(s string, limits DiceLimits) (*RollResult, error) {
+	expr, err := parseExpr(s)
 	if err != nil {
-		return "", errors.New("unable to parse dice quantity")
+		return nil, err
 	}
-	if diceNum > 100 {
-		return "", errors.New("too many dice jfc")
+
+	terms := expr.diceTerms()
+	diceNum := 0
+	for _, term := range terms {
+		if term.sides == 69 {
+			return &RollResult{nice: true}, nil
+		}
+		diceNum += term.quantity
 	}
 
-	diceCeiling, err := strconv.Atoi(plusRaw[0])
+	if diceNum > limits.maxDice {
+		return nil, errors.New("too many dice jfc")
+	}
+
+	for _, term := range terms {
+		if !term.fudge && !limits.validSides(term.sides) {
+			return nil, fmt.Errorf("invalid die type: d%d", term.sides)
+		}
+	}
+
+	// nothing is rolled for an expression that's refused, so a
+	// sealed roller's randomness is only spent on logged rolls
+	min, max, err := expr.bounds()
 	if err != nil {
-		return "", errors.New("unable to parse die type")
+		return nil, err
 	}
+	total := expr.eval(r)
 
-	valid := false
-	for _, kind := range validDice {
-		if diceCeiling == kind {
-			valid = true
-			break
+	res := &RollResult{
+		expr:      expr,
+		modifiers: expr.modifiers(1),
+		total:     total,
+		min:       min,
+		max:       max,
+	}
+
+	for _, term := range terms {
+		res.terms = append(res.terms, term.result())
+		if term.fudge {
+			res.fudge = true
+		}
+		if term.explode != nil {
+			res.unbounded = true
+		}
+		if term.success != nil {
+			res.pool = true
+		}
+	}
+
+	return res, nil
+}
+
+// bounds every intermediate result so that multiplying
+// two of them can't overflow
+const maxMagnitude = 1 << 31
+
+// bounds returns the lowest and highest totals the expression could
+// produce, refusing any too large to work with before a die is rolled
+func (n *exprNode) bounds() (int, int, error) {
+	var lo, hi int
+
+	switch n.kind {
+	case nodeConst:
+		return n.value, n.value, nil
+
+	case nodeDice:
+		lo, hi = n.dice.bounds()
+		return lo, hi, nil
+
+	case nodeGroup:
+		return n.left.bounds()
+
+	case nodeNeg:
+		lo, hi, err := n.left.bounds()
+		return -hi, -lo, err
+	}
+
+	lLo, lHi, err := n.left.bounds()
+	if err != nil {
+		return 0, 0, err
+	}
+	rLo, rHi, err := n.right.bounds()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch n.op {
+	case '+':
+		lo, hi = lLo+rLo, lHi+rHi
+	case '-':
+		lo, hi = lLo-rHi, lHi-rLo
+	case '*':
+		lo, hi = lLo*rLo, lLo*rLo
+		for _, v := range []int{lLo * rHi, lHi * rLo, lHi * rHi} {
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
 		}
 	}
-	if !valid {
-		return "", errors.New("invalid die type")
+
+	if lo < -maxMagnitude || hi > maxMagnitude {
+		return 0, 0, errors.New("number too large")
+	}
+
+	return lo, hi, nil
+}
+
+// eval rolls any dice in the expression and returns the total
+func (n *exprNode) eval(r *Roller) int {
+	switch n.kind {
+	case nodeConst:
+		return n.value
+
+	case nodeDice:
+		n.dice.total = n.dice.roll(r)
+		return n.dice.total
+
+	case nodeGroup:
+		return n.left.eval(r)
+
+	case nodeNeg:
+		return -n.left.eval(r)
 	}
 
-	var out strings.Builder
-	var totes []int
+	left := n.left.eval(r)
+	right := n.right.eval(r)
 
-	for i := 0; i < diceNum; i++ {
-		res := getRoll(diceCeiling)
-		totes = append(totes, res)
-		if i == diceNum-1 {
-			out.WriteString(fmt.Sprintf("%d", res))
-		} else {
-			out.WriteString(fmt.Sprintf("%d  ", res))
+	switch n.op {
+	case '-':
+		return left - right
+	case '*':
+		return left * right
+	}
+	return left + right
+}
+
+// String renders the expression with each dice group's rolls in brackets
+func (n *exprNode) String() string {
+	switch n.kind {
+	case nodeConst:
+		return fmt.Sprintf("%d", n.value)
+	case nodeDice:
+		return fmt.Sprintf("%s[%s]", n.dice.notation, joinRolls(n.dice.rolls, " "))
+	case nodeGroup:
+		return fmt.Sprintf("(%s)", n.left)
+	case nodeNeg:
+		return fmt.Sprintf("-%s", n.left)
+	}
+
+	return fmt.Sprintf("%s %c %s", n.left, n.op, n.right)
+}
+
+// modifiers returns the constants added to or subtracted from the
+// expression outside of any multiplication, with their signs
+func (n *exprNode) modifiers(sign int) []int {
+	switch {
+	case n.kind == nodeConst:
+		return []int{sign * n.value}
+	case n.kind == nodeGroup:
+		return n.left.modifiers(sign)
+	case n.kind == nodeNeg:
+		return n.left.modifiers(-sign)
+	case n.kind == nodeBinary && n.op == '+':
+		return append(n.left.modifiers(sign), n.right.modifiers(sign)...)
+	case n.kind == nodeBinary && n.op == '-':
+		return append(n.left.modifiers(sign), n.right.modifiers(-sign)...)
+	}
+	return nil
+}
+
+// dieRoll is a single die thrown for a dice term
+type dieRoll struct {
+	raw      int
+	face     int
+	rerolled bool
+	exploded bool
+	compound bool
+	dropped  bool
+	success  bool
+	fudge    bool
+	crit     bool
+	fumble   bool
+}
+
+// stops a lucky streak of exploding dice from running away
+const maxExplosions = 20
+
+// roll throws every die in the term, applying its modifiers,
+// and returns the total of the kept dice
+func (t *diceTerm) roll(r *Roller) int {
+	t.rolls = t.rolls[:0]
+
+	for i := 0; i < t.quantity; i++ {
+		d := t.rollDie(r)
+		if t.explode == nil {
+			t.rolls = append(t.rolls, d)
+			continue
+		}
+
+		for n := 0; n < maxExplosions && t.explode.match(d.raw); n++ {
+			if t.compound {
+				next := t.rollDie(r)
+				d.face += next.face
+				d.raw = next.raw
+				d.compound = true
+				continue
+			}
+			d.exploded = true
+			t.rolls = append(t.rolls, d)
+			d = t.rollDie(r)
 		}
+		t.rolls = append(t.rolls, d)
+	}
+
+	t.applyKeep()
+	t.markCrits()
+
+	if t.success != nil {
+		return t.countSuccesses()
 	}
 
 	total := 0
-	for _, d := range totes {
-		total += d
+	for _, d := range t.rolls {
+		if !d.dropped && !d.rerolled {
+			total += d.face
+		}
+	}
+	return total
+}
+
+// markCrits flags kept dice whose natural face is in the
+// term's critical hit or fumble range
+func (t *diceTerm) markCrits() {
+	t.crits, t.fumbles = nil, nil
+
+	for i, d := range t.rolls {
+		if d.dropped || d.rerolled {
+			continue
+		}
+		switch {
+		case t.critHit != nil && t.critHit.match(d.raw):
+			t.rolls[i].crit = true
+			t.crits = append(t.crits, d.raw)
+		case t.critMiss != nil && t.critMiss.match(d.raw):
+			t.rolls[i].fumble = true
+			t.fumbles = append(t.fumbles, d.raw)
+		}
 	}
+}
+
+// countSuccesses marks and tallies the kept dice of a pool,
+// returning the successes less any failures
+func (t *diceTerm) countSuccesses() int {
+	t.successes, t.failures = 0, 0
 
-	max := diceCeiling*diceNum + plus
-	out.WriteString(fmt.Sprintf(",  total: %d/%d", total+plus, max))
+	for i, d := range t.rolls {
+		if d.dropped || d.rerolled {
+			continue
+		}
+		switch {
+		case t.success.match(d.face):
+			t.rolls[i].success = true
+			t.successes++
+		case t.failure != nil && t.failure.match(d.face):
+			t.failures++
+		}
+	}
+
+	return t.successes - t.failures
+}
+
+// outcome describes a botched or glitched pool, if it was
+func (t *diceTerm) outcome() string {
+	if t.failure != nil && t.failures > 0 && t.successes == 0 {
+		return "botch!"
+	}
+	if !t.glitch {
+		return ""
+	}
+
+	dice, ones := 0, 0
+	for _, d := range t.rolls {
+		if d.dropped || d.rerolled {
+			continue
+		}
+		dice++
+		if d.raw == 1 {
+			ones++
+		}
+	}
+
+	switch {
+	case ones*2 <= dice:
+		return ""
+	case t.successes == 0:
+		return "critical glitch!"
+	}
+	return "glitch!"
+}
+
+// rollDie throws a single die, rerolling and raising it as needed.
+// Any rerolled faces are recorded ahead of it as discarded dice.
+func (t *diceTerm) rollDie(r *Roller) dieRoll {
+	raw := r.getRoll(t.sides)
+
+	for n := 0; t.reroll != nil && t.reroll.match(raw); n++ {
+		if (t.rerollOnce && n == 1) || n == maxExplosions {
+			break
+		}
+		t.rolls = append(t.rolls, dieRoll{raw: raw, face: raw, rerolled: true})
+		raw = r.getRoll(t.sides)
+	}
+
+	if t.fudge {
+		return dieRoll{raw: raw - 2, face: raw - 2, fudge: true}
+	}
+
+	face := raw
+	if face < t.floor {
+		face = t.floor
+	}
+
+	return dieRoll{raw: raw, face: face}
+}
+
+// bounds is the lowest and highest total the term can roll,
+// ignoring any explosions
+func (t *diceTerm) bounds() (int, int) {
+	kept := t.keep
+	if kept == 0 {
+		kept = t.quantity
+	}
+
+	if t.success != nil {
+		if t.failure != nil {
+			return -kept, kept
+		}
+		return 0, kept
+	}
+	if t.fudge {
+		return -kept, kept
+	}
+
+	lo := t.lowestFace()
+	if lo < t.floor {
+		lo = t.floor
+	}
+
+	hi := t.sides
+	for t.reroll != nil && !t.rerollOnce && t.reroll.match(hi) {
+		hi--
+	}
+	if hi < t.floor {
+		hi = t.floor
+	}
+
+	return kept * lo, kept * hi
+}
+
+// applyKeep marks every die outside the kept highest
+// or lowest dice as dropped
+func (t *diceTerm) applyKeep() {
+	var order []int
+	for i, d := range t.rolls {
+		if !d.rerolled {
+			order = append(order, i)
+		}
+	}
+	if t.keep == 0 || t.keep >= len(order) {
+		return
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		if t.keepHigh {
+			return t.rolls[order[a]].face > t.rolls[order[b]].face
+		}
+		return t.rolls[order[a]].face < t.rolls[order[b]].face
+	})
+
+	for _, i := range order[t.keep:] {
+		t.rolls[i].dropped = true
+	}
+}
+
+// joinRolls lists the rolled dice. Dropped and rerolled dice are in
+// parentheses, exploding dice are marked with !, raised dice with →
+// and successes in a pool with *
+func joinRolls(rolls []dieRoll, sep string) string {
+	out := make([]string, 0, len(rolls))
+	for _, d := range rolls {
+		out = append(out, d.String())
+	}
+	return strings.Join(out, sep)
+}
+
+func (d dieRoll) String() string {
+	if d.fudge {
+		return fudgeFaces[d.face+1]
+	}
+	if d.rerolled || d.dropped {
+		return fmt.Sprintf("(%d)", d.face)
+	}
+
+	out := fmt.Sprintf("%d", d.face)
+	switch {
+	case d.compound:
+		out += "!!"
+	case d.exploded:
+		out += "!"
+	case d.raw != d.face:
+		out = fmt.Sprintf("%d→%d", d.raw, d.face)
+	}
+
+	if d.success {
+		out += "*"
+	}
 
-	return out.String(), nil
+	return out
 }
@@ -6,6 +6,8 @@ import (
 	"testing"
 )
 
+var testRoller = newCryptoRoller()
+
 var getRollCases = []struct {
 	name    string
 	ceiling int
@@ -43,7 +45,7 @@ var getRollCases = []struct {
 func Test_getRoll(t *testing.T) {
 	for _, tt := range getRollCases {
 		t.Run(tt.name, func(t *testing.T) {
-			out := getRoll(tt.ceiling)
+			out := testRoller.getRoll(tt.ceiling)
 			if out < 1 || out > tt.ceiling {
 				t.Errorf("Roll out of range: %d of ceiling %d", out, tt.ceiling)
 			}
@@ -91,6 +93,12 @@ var parseDiceCases = []struct {
 		raw:      "2d30",
 		ceiling:  30,
 		quantity: 2,
+		wantErr:  false,
+	},
+	{
+		raw:      "1d1001",
+		ceiling:  1001,
+		quantity: 1,
 		wantErr:  true,
 	},
 	{
@@ -129,6 +137,18 @@ var parseDiceCases = []struct {
 		quantity: 1,
 		wantErr:  false,
 	},
+	{
+		raw:      "1d20-1",
+		ceiling:  20,
+		quantity: 1,
+		wantErr:  false,
+	},
+	{
+		raw:      "1d20+2+3",
+		ceiling:  20,
+		quantity: 1,
+		wantErr:  false,
+	},
 	{
 		raw:      "101d10",
 		ceiling:  10,
@@ -140,7 +160,7 @@ var parseDiceCases = []struct {
 func Test_parseDice(t *testing.T) {
 	for _, tt := range parseDiceCases {
 		t.Run(tt.raw, func(t *testing.T) {
-			out, err := parseDice(tt.raw)
+			out, err := testRoller.parseDice(tt.raw, defaultDiceLimits)
 			if tt.raw == "1d69" && out != "n i c e" {
 				t.Errorf("69 didn't return n i c e")
 			}
@@ -170,10 +190,544 @@ func Test_parseDice(t *testing.T) {
 	}
 }
 
+var parseDiceExprCases = []struct {
+	raw       string
+	min       int
+	max       int
+	unbounded bool
+	wantErr   bool
+}{
+	{
+		raw: "2d6+1d4+3",
+		min: 6,
+		max: 19,
+	},
+	{
+		raw: "1d20-2",
+		min: -1,
+		max: 18,
+	},
+	{
+		raw: "(1d8+2)*2",
+		min: 6,
+		max: 20,
+	},
+	{
+		raw: "d20+d4",
+		min: 2,
+		max: 24,
+	},
+	{
+		raw: "10-1d4",
+		min: 6,
+		max: 9,
+	},
+	{
+		raw: "-1d6*2",
+		min: -12,
+		max: -2,
+	},
+	{
+		raw: "4d6kh3",
+		min: 3,
+		max: 18,
+	},
+	{
+		raw: "2d20kh1+5",
+		min: 6,
+		max: 25,
+	},
+	{
+		raw: "2d20kl1",
+		min: 1,
+		max: 20,
+	},
+	{
+		raw: "4d6dl1+4d6dh2",
+		min: 5,
+		max: 30,
+	},
+	{
+		raw: "4d6r1",
+		min: 8,
+		max: 24,
+	},
+	{
+		raw: "1d6r<3",
+		min: 3,
+		max: 6,
+	},
+	{
+		raw: "1d6r>4",
+		min: 1,
+		max: 4,
+	},
+	{
+		raw: "2d6ro1",
+		min: 2,
+		max: 12,
+	},
+	{
+		raw: "2d6min3",
+		min: 6,
+		max: 12,
+	},
+	{
+		raw:       "3d6!",
+		min:       3,
+		unbounded: true,
+	},
+	{
+		raw:       "2d6!!+1",
+		min:       3,
+		unbounded: true,
+	},
+	{
+		raw:     "2d6+",
+		wantErr: true,
+	},
+	{
+		raw:     "2d20kh3",
+		wantErr: true,
+	},
+	{
+		raw:     "2d6dl2",
+		wantErr: true,
+	},
+	{
+		raw:     "1d6zz",
+		wantErr: true,
+	},
+	{
+		raw:     "(1d8+2",
+		wantErr: true,
+	},
+	{
+		raw:     "1d6+1d1001",
+		wantErr: true,
+	},
+	{
+		raw:     "60d6+60d6",
+		wantErr: true,
+	},
+	{
+		raw:     "0d6",
+		wantErr: true,
+	},
+	{
+		raw:     "1d6*1000000*1000000",
+		wantErr: true,
+	},
+}
+
+func Test_parseDiceExpr(t *testing.T) {
+	for _, tt := range parseDiceExprCases {
+		t.Run(tt.raw, func(t *testing.T) {
+			out, err := testRoller.parseDice(tt.raw, defaultDiceLimits)
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got nil: %s", out)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			split := strings.Split(out, "total: ")
+			split = strings.Split(split[len(split)-1], ",")
+			split = strings.Split(split[0], "/")
+			total, _ := strconv.Atoi(split[0])
+			if tt.unbounded {
+				if total < tt.min || len(split) != 1 {
+					t.Errorf("Expected unbounded total of at least %d, got %s", tt.min, out)
+				}
+				return
+			}
+
+			max, _ := strconv.Atoi(split[1])
+			if total < tt.min || total > tt.max {
+				t.Errorf("Total out of range: %s", out)
+			}
+			if max != tt.max {
+				t.Errorf("Expected max %d, got %s", tt.max, out)
+			}
+		})
+	}
+}
+
+var applyKeepCases = []struct {
+	name     string
+	faces    []int
+	keep     int
+	keepHigh bool
+	want     string
+}{
+	{
+		name:     "keep highest 3",
+		faces:    []int{3, 6, 1, 4},
+		keep:     3,
+		keepHigh: true,
+		want:     "3 6 (1) 4",
+	},
+	{
+		name:     "keep lowest 1",
+		faces:    []int{17, 4},
+		keep:     1,
+		keepHigh: false,
+		want:     "(17) 4",
+	},
+	{
+		name:     "keep highest with ties",
+		faces:    []int{5, 5, 5},
+		keep:     2,
+		keepHigh: true,
+		want:     "5 5 (5)",
+	},
+	{
+		name:     "keep all",
+		faces:    []int{2, 1},
+		keep:     2,
+		keepHigh: true,
+		want:     "2 1",
+	},
+}
+
+func Test_applyKeep(t *testing.T) {
+	for _, tt := range applyKeepCases {
+		t.Run(tt.name, func(t *testing.T) {
+			term := &diceTerm{
+				quantity: len(tt.faces),
+				keep:     tt.keep,
+				keepHigh: tt.keepHigh,
+			}
+			for _, face := range tt.faces {
+				term.rolls = append(term.rolls, dieRoll{raw: face, face: face})
+			}
+			term.applyKeep()
+			if got := joinRolls(term.rolls, " "); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+var dieRollStringCases = []struct {
+	name string
+	roll dieRoll
+	want string
+}{
+	{
+		name: "plain",
+		roll: dieRoll{raw: 4, face: 4},
+		want: "4",
+	},
+	{
+		name: "rerolled",
+		roll: dieRoll{raw: 1, face: 1, rerolled: true},
+		want: "(1)",
+	},
+	{
+		name: "exploded",
+		roll: dieRoll{raw: 6, face: 6, exploded: true},
+		want: "6!",
+	},
+	{
+		name: "compounded",
+		roll: dieRoll{raw: 3, face: 15, compound: true},
+		want: "15!!",
+	},
+	{
+		name: "raised",
+		roll: dieRoll{raw: 1, face: 2},
+		want: "1→2",
+	},
+}
+
+func Test_dieRollString(t *testing.T) {
+	for _, tt := range dieRollStringCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.roll.String(); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+var countSuccessesCases = []struct {
+	name    string
+	raw     string
+	faces   []int
+	want    int
+	outcome string
+}{
+	{
+		name:  "world of darkness",
+		raw:   "6d10>=8",
+		faces: []int{10, 8, 7, 1, 3, 9},
+		want:  3,
+	},
+	{
+		name:    "botch",
+		raw:     "4d10>=8f1",
+		faces:   []int{1, 4, 7, 2},
+		want:    -1,
+		outcome: "botch!",
+	},
+	{
+		name:  "ones cancel successes without a botch",
+		raw:   "3d10>=8f1",
+		faces: []int{1, 9, 2},
+		want:  0,
+	},
+	{
+		name:    "glitch",
+		raw:     "4d6>=5gl",
+		faces:   []int{1, 1, 1, 6},
+		want:    1,
+		outcome: "glitch!",
+	},
+	{
+		name:    "critical glitch",
+		raw:     "3d6>=5gl",
+		faces:   []int{1, 1, 4},
+		want:    0,
+		outcome: "critical glitch!",
+	},
+	{
+		name:  "half ones isn't a glitch",
+		raw:   "4d6>=5gl",
+		faces: []int{1, 1, 3, 4},
+		want:  0,
+	},
+}
+
+func Test_countSuccesses(t *testing.T) {
+	for _, tt := range countSuccessesCases {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parseExpr(tt.raw)
+			if err != nil {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			term := expr.dice
+			for _, face := range tt.faces {
+				term.rolls = append(term.rolls, dieRoll{raw: face, face: face})
+			}
+
+			if got := term.countSuccesses(); got != tt.want {
+				t.Errorf("Expected %d successes, got %d", tt.want, got)
+			}
+			if got := term.outcome(); got != tt.outcome {
+				t.Errorf("Expected outcome '%s', got '%s'", tt.outcome, got)
+			}
+		})
+	}
+}
+
+func Test_parseDicePool(t *testing.T) {
+	for _, raw := range []string{"8d10>=8", "12d6>=5", "8d10>=8!f1", "5d10>7+2"} {
+		t.Run(raw, func(t *testing.T) {
+			out, err := testRoller.parseDice(raw, defaultDiceLimits)
+			if err != nil {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if !strings.Contains(out, "successes: ") {
+				t.Errorf("Expected success count, got %s", out)
+			}
+		})
+	}
+}
+
+var fateLadderCases = []struct {
+	total int
+	want  string
+}{
+	{total: -3, want: "below Terrible"},
+	{total: -2, want: "Terrible"},
+	{total: 0, want: "Mediocre"},
+	{total: 3, want: "Good"},
+	{total: 8, want: "Legendary"},
+	{total: 9, want: "beyond Legendary"},
+}
+
+func Test_fateLadder(t *testing.T) {
+	for _, tt := range fateLadderCases {
+		t.Run(strconv.Itoa(tt.total), func(t *testing.T) {
+			if got := fateLadder(tt.total); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+var parseDiceFudgeCases = []struct {
+	raw  string
+	dice int
+}{
+	{raw: "4dF", dice: 4},
+	{raw: "4df+2", dice: 4},
+	{raw: "dF", dice: 1},
+}
+
+func Test_parseDiceFudge(t *testing.T) {
+	for _, tt := range parseDiceFudgeCases {
+		t.Run(tt.raw, func(t *testing.T) {
+			out, err := testRoller.parseDice(tt.raw, defaultDiceLimits)
+			if err != nil {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+
+			split := strings.Split(out, "total: ")
+			split = strings.Split(split[len(split)-1], ",")
+			split = strings.SplitN(split[0], " ", 2)
+			total, err := strconv.Atoi(split[0])
+			if err != nil {
+				t.Fatalf("Couldn't read total from %s", out)
+			}
+			if split[1] != fateLadder(total) {
+				t.Errorf("Expected ladder %s, got %s", fateLadder(total), out)
+			}
+			faces := strings.Count(out, "[+]") + strings.Count(out, "[ ]") + strings.Count(out, "[-]")
+			if faces != tt.dice {
+				t.Errorf("Expected %d fudge faces, got %s", tt.dice, out)
+			}
+		})
+	}
+}
+
+var validSidesCases = []struct {
+	name   string
+	limits DiceLimits
+	sides  int
+	want   bool
+}{
+	{
+		name:   "coin flip",
+		limits: defaultDiceLimits,
+		sides:  2,
+		want:   true,
+	},
+	{
+		name:   "d1000",
+		limits: defaultDiceLimits,
+		sides:  1000,
+		want:   true,
+	},
+	{
+		name:   "too big",
+		limits: DiceLimits{maxSides: 100, maxDice: 100},
+		sides:  120,
+		want:   false,
+	},
+	{
+		name:   "allowed",
+		limits: DiceLimits{allowed: []int{4, 6, 8}, maxSides: 1000, maxDice: 100},
+		sides:  6,
+		want:   true,
+	},
+	{
+		name:   "not allowed",
+		limits: DiceLimits{allowed: []int{4, 6, 8}, maxSides: 1000, maxDice: 100},
+		sides:  3,
+		want:   false,
+	},
+}
+
+func Test_validSides(t *testing.T) {
+	for _, tt := range validSidesCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limits.validSides(tt.sides); got != tt.want {
+				t.Errorf("Expected %v for d%d, got %v", tt.want, tt.sides, got)
+			}
+		})
+	}
+}
+
+func Test_parseDiceLimits(t *testing.T) {
+	limits := DiceLimits{maxSides: 20, maxDice: 3}
+
+	if _, err := testRoller.parseDice("3d20", limits); err != nil {
+		t.Errorf("Got unexpected error: %s", err.Error())
+	}
+	if _, err := testRoller.parseDice("2d6+2d6", limits); err == nil {
+		t.Error("Rolled more dice than allowed")
+	}
+	if _, err := testRoller.parseDice("1d30", limits); err == nil {
+		t.Error("Rolled a die larger than allowed")
+	}
+}
+
+var parseDiceSeededCases = []struct {
+	raw  string
+	want string
+}{
+	{raw: "1d20", want: "2,  total: 2/20"},
+	{raw: "4d6kh3", want: "6  (4)  6  6,  total: 18/18"},
+	{raw: "2d6+1d4+3", want: "2d6[6 4] + 1d4[4] + 3,  total: 17/19"},
+	{raw: "3d6!", want: "6!  4  6!  6!  2  1,  total: 25"},
+	{raw: "8d10>=8f1", want: "2  8*  8*  10*  2  9*  6  1,  successes: 3/8"},
+	{raw: "4dF+1", want: "[+]  [-]  [+]  [+],  total: +3 Good"},
+}
+
+func Test_parseDiceSeeded(t *testing.T) {
+	for _, tt := range parseDiceSeededCases {
+		t.Run(tt.raw, func(t *testing.T) {
+			out, err := newSeededRoller(1).parseDice(tt.raw, defaultDiceLimits)
+			if err != nil {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if out != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, out)
+			}
+		})
+	}
+}
+
+// fixedSource rolls the given faces in order, then 1s
+type fixedSource struct {
+	faces []int
+}
+
+func (f *fixedSource) Intn(n int) int {
+	if len(f.faces) == 0 {
+		return 0
+	}
+	face := f.faces[0]
+	f.faces = f.faces[1:]
+	return face - 1
+}
+
+func fixedRoller(faces ...int) *Roller {
+	return newRoller(&fixedSource{faces: faces})
+}
+
+func Test_newSeededRoller(t *testing.T) {
+	a, b := newSeededRoller(42), newSeededRoller(42)
+	for i := 0; i < 100; i++ {
+		if x, y := a.getRoll(20), b.getRoll(20); x != y {
+			t.Fatalf("Rolls diverged on roll %d: %d vs %d", i, x, y)
+		}
+	}
+}
+
+func Test_cryptoSource(t *testing.T) {
+	seen := make(map[int]bool)
+	for i := 0; i < 1000; i++ {
+		out := testRoller.getRoll(4)
+		if out < 1 || out > 4 {
+			t.Fatalf("Roll out of range: %d of ceiling 4", out)
+		}
+		seen[out] = true
+	}
+	if len(seen) != 4 {
+		t.Errorf("Expected every face of a d4 in 1000 rolls, got %v", seen)
+	}
+}
+
 func Benchmark_parseDice(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		for _, tt := range parseDiceCases {
-			parseDice(tt.raw)
+			testRoller.parseDice(tt.raw, defaultDiceLimits)
 		}
 	}
 }
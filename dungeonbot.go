@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,6 +35,11 @@ type Config struct {
 	pastebinURL string
 	dbLocation  string
 	signOff     string
+	dice        DiceLimits
+	chanDice    map[string]DiceLimits
+	crits       CritConf
+	gms         map[string][]string
+	campaigns   map[string]string
 }
 
 func main() {
@@ -57,6 +63,7 @@ func main() {
 	conn.TLSConfig = &tls.Config{InsecureSkipVerify: false}
 
 	db := initDB(conf.dbLocation)
+	roller := newCryptoRoller()
 
 	conn.AddCallback("001", func(e *irc.Event) {
 		for i := 0; i < len(conf.chans); i++ {
@@ -72,6 +79,19 @@ func main() {
 			return
 		}
 
+		if !strings.HasPrefix(e.Message(), "!") && inlineRegex.MatchString(e.Message()) {
+			r, seal, seq := db.sealedRoller(target, roller)
+			out, n, rows := r.inlineRolls(e.Nick, target, e.Message(), conf.diceLimits(target), conf.crits.format)
+			if n > 0 {
+				conn.Privmsgf(target, "%s: %s", e.Nick, out)
+				sealRows(rows, seal, seq)
+				if err := db.logRolls(rows); err != nil {
+					log.Printf("When logging inline rolls: %s", err.Error())
+				}
+				return
+			}
+		}
+
 		msg := strings.Split(e.Message(), " ")
 		user := strings.ToLower(e.Nick)
 
@@ -85,20 +105,357 @@ func main() {
 			conn.Privmsg(target, helpText)
 		}
 
+		// !attack rolls the macro attack
+		if name := strings.TrimPrefix(cmd, "!"); name != cmd && macroNameRegex.MatchString(name) && !isReserved(name) {
+			macros, err := db.getMacros(user, conf.campaignFor(target))
+			if err != nil {
+				log.Printf("When retrieving macros for %s: %s", user, err.Error())
+			}
+			if _, ok := macroLookup(macros)[name]; ok {
+				msg = append([]string{"!roll", name}, msg[1:]...)
+				cmd = "!roll"
+			}
+		}
+
 		switch cmd {
-		case "!roll":
+		case "!roll", "!sroll", "!gmroll":
 			if len(msg) < 2 {
 				conn.Privmsg(target, "Missing dice argument. Eg: !roll 1d20")
 				break
 			}
 
-			out, err := parseDice(msg[1])
+			macros, err := db.getMacros(user, conf.campaignFor(target))
+			if err != nil {
+				log.Printf("When retrieving macros for %s: %s", user, err.Error())
+			}
+
+			parts, err := expandMacros(strings.Join(msg[1:], " "), macroLookup(macros))
+			if err != nil {
+				conn.Privmsg(target, err.Error())
+				break
+			}
+
+			for _, part := range parts {
+				if !rollCommand(conn, db, conf, roller, cmd, e.Nick, target, part) {
+					break
+				}
+			}
+
+		case "!history":
+			nick, limit, err := parseHistoryArgs(msg[1:])
+			if err != nil {
+				conn.Privmsg(target, err.Error())
+				break
+			}
+
+			rows, err := db.getRolls(strings.ToLower(target), nick, limit)
+			if err != nil {
+				conn.Privmsg(target, "Error retrieving roll history")
+				log.Printf("When retrieving roll history: %s", err.Error())
+				break
+			}
+			if len(rows) == 0 {
+				conn.Privmsg(target, "No rolls to show")
+				break
+			}
+
+			for _, row := range rows {
+				conn.Privmsg(target, row.String())
+			}
+
+		case "!rollstats":
+			if len(msg) > 1 && strings.ToLower(msg[1]) == "campaign" {
+				if len(msg) < 3 {
+					conn.Privmsg(target, "Missing campaign name. Eg: !rollstats campaign gronkulousness")
+					break
+				}
+				name := strings.ToLower(msg[2])
+
+				users, err := db.getCampaignUsers(name)
+				if err != nil {
+					conn.Privmsgf(target, "No campaign called %s", name)
+					log.Printf("When retrieving users for campaign '%s': %s", name, err.Error())
+					break
+				}
+
+				rolls := make(map[string][]string)
+				for _, u := range users {
+					if rolls[u], err = db.getRollDice(u); err != nil {
+						log.Printf("When retrieving rolls for '%s': %s", u, err.Error())
+					}
+				}
+
+				board := leaderboard(rolls)
+				if len(board) == 0 {
+					conn.Privmsgf(target, "No rolls yet for %s", name)
+					break
+				}
+
+				ranks := make([]string, 0, len(board))
+				for i, row := range board {
+					ranks = append(ranks, fmt.Sprintf("%d. %s %+.1f%% (%d dice)", i+1, row.nick, row.luck, row.dice))
+				}
+				conn.Privmsgf(target, "Luck in %s: %s", name, strings.Join(ranks, "  "))
+				break
+			}
+
+			nick, die, err := parseRollStatsArgs(msg[1:])
+			if err != nil {
+				conn.Privmsg(target, err.Error())
+				break
+			}
+
+			rolls, err := db.getRollDice(nick)
+			if err != nil {
+				conn.Privmsg(target, "Error retrieving roll history")
+				log.Printf("When retrieving roll stats: %s", err.Error())
+				break
+			}
+
+			who := nick
+			if who == "" {
+				who = "everyone"
+			}
+			lines, err := rollStats(who, rolls, die)
+			if err != nil {
+				conn.Privmsg(target, err.Error())
+				break
+			}
+			for _, line := range lines {
+				conn.Privmsg(target, line)
+			}
+
+		case "!commit":
+			if !canSeal(e.Nick, conf.gmsFor(target)) {
+				conn.Privmsg(target, "Only this channel's GMs can seal its rolls")
+				break
+			}
+
+			seal, err := db.getSeal(target, 0, false)
+			if err != nil {
+				conn.Privmsg(target, "Error retrieving seal")
+				log.Printf("When retrieving seal for %s: %s", target, err.Error())
+				break
+			}
+			if seal != nil {
+				conn.Privmsgf(target, "Rolls here are already sealed: seal %d, sha256 %s", seal.id, seal.hash)
+				break
+			}
+
+			if seal, err = newSeal(target); err == nil {
+				err = db.addSeal(seal)
+			}
+			if err != nil {
+				conn.Privmsg(target, "Error sealing rolls")
+				log.Printf("When sealing rolls for %s: %s", target, err.Error())
+				break
+			}
+			conn.Privmsgf(target, "Rolls here are now sealed. Seal %d commits to a seed with sha256 %s", seal.id, seal.hash)
+
+		case "!reveal":
+			if !canSeal(e.Nick, conf.gmsFor(target)) {
+				conn.Privmsg(target, "Only this channel's GMs can reveal its seal")
+				break
+			}
+
+			seal, err := db.getSeal(target, 0, false)
+			if err != nil {
+				conn.Privmsg(target, "Error retrieving seal")
+				log.Printf("When retrieving seal for %s: %s", target, err.Error())
+				break
+			}
+			if seal == nil {
+				conn.Privmsg(target, "Rolls here aren't sealed. Seal them with !commit")
+				break
+			}
+
+			if err := db.revealSeal(seal.id); err != nil {
+				conn.Privmsg(target, "Error revealing seal")
+				log.Printf("When revealing seal %d: %s", seal.id, err.Error())
+				break
+			}
+			conn.Privmsgf(target, "Seal %d revealed. Seed: %s (sha256 %s). Check its rolls with !verify %d", seal.id, seal.seed, seal.hash, seal.id)
+
+		case "!verify":
+			id := 0
+			if len(msg) > 1 {
+				n, err := strconv.Atoi(msg[1])
+				if err != nil || n < 1 {
+					conn.Privmsg(target, "Invalid seal. Eg: !verify 3")
+					break
+				}
+				id = n
+			}
+
+			seal, err := db.getSeal(target, id, true)
+			if err != nil {
+				conn.Privmsg(target, "Error retrieving seal")
+				log.Printf("When retrieving seal %d: %s", id, err.Error())
+				break
+			}
+			if seal == nil {
+				conn.Privmsg(target, "No revealed seal to verify")
+				break
+			}
+			if !seal.revealed {
+				conn.Privmsgf(target, "Seal %d hasn't been revealed yet", seal.id)
+				break
+			}
+
+			rows, err := db.getSealRolls(seal.id)
+			if err != nil {
+				conn.Privmsg(target, "Error retrieving sealed rolls")
+				log.Printf("When retrieving rolls for seal %d: %s", seal.id, err.Error())
+				break
+			}
+
+			checked, bad := seal.verify(rows)
+			if len(bad) == 0 {
+				conn.Privmsgf(target, "Seal %d: the seed matches its commitment and all %d rolls check out", seal.id, checked)
+				break
+			}
+			conn.Privmsgf(target, "Seal %d: %d problems found in %d rolls: %s", seal.id, len(bad), checked, strings.Join(bad, "; "))
+
+		case "!coc", "!skill", "!pbta", "!blades", "!ironsworn", "!sw", "!nd":
+			sr, err := parseSystemRoll(cmd, msg[1:])
 			if err != nil {
 				conn.Privmsg(target, err.Error())
 				break
 			}
 
-			conn.Privmsg(target, out)
+			if sheet, ok := sr.(SheetRoll); ok {
+				pc, err := db.getPC(user, conf.campaignFor(target))
+				if err != nil {
+					log.Printf("When retrieving character sheet for %s: %s", user, err.Error())
+				}
+				if pc != nil {
+					sheet.useSheet(pc)
+				}
+			}
+
+			r, seal, seq := db.sealedRoller(target, roller)
+			sr.roll(r)
+			rows := []RollRow{sr.historyRow(e.Nick, target)}
+			sealRows(rows, seal, seq)
+			if err := db.logRolls(rows); err != nil {
+				log.Printf("When logging rolls: %s", err.Error())
+			}
+			conn.Privmsg(target, sr.report(e.Nick, conf.crits.format))
+
+		case "!macro":
+			if len(msg) < 2 {
+				conn.Privmsg(target, "Missing macro command. Eg: !macro set attack 1d20+7 # longsword")
+				break
+			}
+
+			switch strings.ToLower(msg[1]) {
+			case "set":
+				m, err := parseMacroSet(user, msg[2:])
+				if err != nil {
+					conn.Privmsg(target, err.Error())
+					break
+				}
+
+				if m.campaign != "" {
+					users, err := db.getCampaignUsers(m.campaign)
+					if err != nil {
+						conn.Privmsgf(target, "No campaign called %s", m.campaign)
+						break
+					}
+					member := false
+					for _, u := range users {
+						member = member || strings.EqualFold(u, user)
+					}
+					if !member {
+						conn.Privmsgf(target, "You're not in campaign %s", m.campaign)
+						break
+					}
+				}
+
+				macros, err := db.getMacros(user, m.campaign)
+				if err != nil {
+					conn.Privmsg(target, "Error retrieving macros")
+					log.Printf("When retrieving macros for %s: %s", user, err.Error())
+					break
+				}
+				if err := m.check(macroLookup(macros)); err != nil {
+					conn.Privmsg(target, err.Error())
+					break
+				}
+
+				if err := db.setMacro(m); err != nil {
+					conn.Privmsg(target, "Error saving macro")
+					log.Printf("When saving macro %s for %s: %s", m.name, user, err.Error())
+					break
+				}
+				conn.Privmsgf(target, "Saved macro %s", m)
+
+			case "del":
+				campaign, args, err := parseMacroArgs(msg[2:])
+				if err != nil {
+					conn.Privmsg(target, err.Error())
+					break
+				}
+				if len(args) < 1 {
+					conn.Privmsg(target, "Missing macro name. Eg: !macro del attack")
+					break
+				}
+
+				name := strings.ToLower(args[0])
+				found, err := db.delMacro(user, campaign, name)
+				if err != nil {
+					conn.Privmsg(target, "Error deleting macro")
+					log.Printf("When deleting macro %s for %s: %s", name, user, err.Error())
+					break
+				}
+				if !found {
+					conn.Privmsgf(target, "No macro called %s", name)
+					break
+				}
+				conn.Privmsgf(target, "Deleted macro %s", name)
+
+			case "list":
+				macros, err := db.getMacros(user, conf.campaignFor(target))
+				if err != nil {
+					conn.Privmsg(target, "Error retrieving macros")
+					log.Printf("When retrieving macros for %s: %s", user, err.Error())
+					break
+				}
+				if len(macros) == 0 {
+					conn.Privmsg(target, "No macros yet. Eg: !macro set attack 1d20+7 # longsword")
+					break
+				}
+
+				list := make([]string, 0, len(macros))
+				for _, m := range macros {
+					list = append(list, m.String())
+				}
+				conn.Privmsgf(target, "%s's macros: %s", e.Nick, strings.Join(list, "  |  "))
+
+			default:
+				conn.Privmsg(target, "Unknown macro command. Try set, del or list")
+			}
+
+		case "!odds":
+			if len(msg) < 2 {
+				conn.Privmsg(target, "Missing roll. Eg: !odds 1d20+7 vs 15 adv")
+				break
+			}
+
+			odds, err := parseOdds(strings.Join(msg[1:], " "))
+			if err == nil {
+				err = odds.within(conf.diceLimits(target))
+			}
+			if err != nil {
+				conn.Privmsg(target, err.Error())
+				break
+			}
+			out, err := odds.report()
+			if err != nil {
+				conn.Privmsg(target, err.Error())
+				break
+			}
+			conn.Privmsgf(target, "%s: %s", e.Nick, out)
 
 		case "!campaign":
 			if len(msg) < 2 {
@@ -229,6 +586,56 @@ func main() {
 	conn.Loop()
 }
 
+// rollCommand makes a single !roll, !sroll or !gmroll, reporting
+// whether it was rolled
+func rollCommand(conn *irc.Connection, db *DB, conf *Config, roller *Roller, cmd, nick, target, raw string) bool {
+	req, err := parseRollRequest(raw)
+	if err != nil {
+		conn.Privmsg(target, err.Error())
+		return false
+	}
+
+	r, seal, seq := db.sealedRoller(target, roller)
+	results, err := req.roll(r, conf.diceLimits(target))
+	if err != nil {
+		conn.Privmsg(target, err.Error())
+		return false
+	}
+
+	rows := req.historyRows(nick, target, results, cmd != "!roll")
+	sealRows(rows, seal, seq)
+	if err := db.logRolls(rows); err != nil {
+		log.Printf("When logging rolls: %s", err.Error())
+	}
+
+	out := req.report(nick, results, conf.crits.format)
+	var tables []string
+	for _, res := range results {
+		if table := conf.crits.tableResult(res, r); table != "" {
+			tables = append(tables, table)
+		}
+	}
+
+	if cmd == "!roll" {
+		conn.Privmsg(target, out)
+		for _, table := range tables {
+			conn.Privmsg(target, table)
+		}
+		return true
+	}
+
+	// secret rolls only go to the roller and the channel's GMs, who
+	// all see the same crit table results
+	for _, to := range secretRecipients(nick, conf.gmsFor(target)) {
+		conn.Noticef(to, "[%s] %s", target, out)
+		for _, table := range tables {
+			conn.Noticef(to, "[%s] %s", target, table)
+		}
+	}
+	conn.Privmsgf(target, "%s rolled secretly", nick)
+	return true
+}
+
 func buildConf() *Config {
 	viper.SetConfigName("dungeonbot")
 	viper.SetConfigType("yml")
@@ -241,6 +648,8 @@ func buildConf() *Config {
 	chanWhole := viper.GetString("chans")
 	chanSep := strings.Split(chanWhole, ",")
 
+	dice, chanDice := readDiceConf(viper.GetViper())
+
 	return &Config{
 		debug:       viper.GetBool("debug_mode"),
 		nick:        viper.GetString("nick"),
@@ -252,7 +661,65 @@ func buildConf() *Config {
 		pastebinURL: viper.GetString("pastebin_url"),
 		dbLocation:  viper.GetString("database_location"),
 		signOff:     viper.GetString("signoff"),
+		dice:        dice,
+		chanDice:    chanDice,
+		crits: CritConf{
+			format:  viper.GetBool("crits.format"),
+			hits:    viper.GetStringSlice("crits.hit_table"),
+			fumbles: viper.GetStringSlice("crits.fumble_table"),
+		},
+		gms:       viper.GetStringMapStringSlice("gms"),
+		campaigns: viper.GetStringMapString("campaigns"),
+	}
+}
+
+// readDiceConf reads the global and per-channel dice limits
+func readDiceConf(v *viper.Viper) (DiceLimits, map[string]DiceLimits) {
+	dice := readDiceLimits(v.Sub("dice"), defaultDiceLimits)
+
+	chanDice := make(map[string]DiceLimits)
+	for name := range v.GetStringMap("dice.channels") {
+		chanDice[name] = readDiceLimits(v.Sub("dice.channels."+name), dice)
+	}
+
+	return dice, chanDice
+}
+
+// readDiceLimits overrides any of the limits in base that
+// are set in the given section of dungeonbot.yml
+func readDiceLimits(v *viper.Viper, base DiceLimits) DiceLimits {
+	if v == nil {
+		return base
+	}
+	if v.IsSet("allowed") {
+		base.allowed = v.GetIntSlice("allowed")
+	}
+	if v.IsSet("max_sides") {
+		base.maxSides = v.GetInt("max_sides")
+	}
+	if v.IsSet("max_dice") {
+		base.maxDice = v.GetInt("max_dice")
+	}
+	return base
+}
+
+// gmsFor returns the nicks of the GMs running games in a channel
+func (c *Config) gmsFor(target string) []string {
+	return c.gms[strings.ToLower(target)]
+}
+
+// campaignFor returns the campaign played in a channel, if any
+func (c *Config) campaignFor(target string) string {
+	return strings.ToLower(c.campaigns[strings.ToLower(target)])
+}
+
+// diceLimits returns the limits for rolls made in the given
+// channel, falling back to the global limits
+func (c *Config) diceLimits(target string) DiceLimits {
+	if limits, ok := c.chanDice[strings.ToLower(target)]; ok {
+		return limits
 	}
+	return c.dice
 }
 
 func watchForInterrupt(conn *irc.Connection, db *sql.DB, conf *Config) {
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const testDiceConf = `
+dice:
+  max_sides: 100
+  allowed: [4, 6, 8]
+  channels:
+    "#ttrpg":
+      max_dice: 10
+    "#Coins":
+      allowed: [2]
+`
+
+func Test_readDiceConf(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yml")
+	if err := v.ReadConfig(bytes.NewBufferString(testDiceConf)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	conf := &Config{}
+	conf.dice, conf.chanDice = readDiceConf(v)
+
+	want := DiceLimits{allowed: []int{4, 6, 8}, maxSides: 100, maxDice: 100}
+	if got := conf.diceLimits("#elsewhere"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected global limits %v, got %v", want, got)
+	}
+
+	want.maxDice = 10
+	if got := conf.diceLimits("#ttrpg"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected #ttrpg limits %v, got %v", want, got)
+	}
+
+	want = DiceLimits{allowed: []int{2}, maxSides: 100, maxDice: 100}
+	if got := conf.diceLimits("#coins"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected #coins limits %v, got %v", want, got)
+	}
+
+	if got, _ := readDiceConf(viper.New()); !reflect.DeepEqual(got, defaultDiceLimits) {
+		t.Errorf("Expected default limits, got %v", got)
+	}
+}
+
+const testGMConf = `
+gms:
+  "#TTRPG":
+    - "gbmor"
+    - "cogm"
+`
+
+func Test_gmsFor(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yml")
+	if err := v.ReadConfig(bytes.NewBufferString(testGMConf)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	conf := &Config{gms: v.GetStringMapStringSlice("gms")}
+	if got := conf.gmsFor("#ttrpg"); !reflect.DeepEqual(got, []string{"gbmor", "cogm"}) {
+		t.Errorf("Expected [gbmor cogm], got %v", got)
+	}
+	if got := conf.gmsFor("#elsewhere"); got != nil {
+		t.Errorf("Expected no GMs, got %v", got)
+	}
+}
+
+const testCampaignConf = `
+campaigns:
+  "#TTRPG": "Gronkulousness"
+`
+
+func Test_campaignFor(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yml")
+	if err := v.ReadConfig(bytes.NewBufferString(testCampaignConf)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	conf := &Config{campaigns: v.GetStringMapString("campaigns")}
+	if got := conf.campaignFor("#ttrpg"); got != "gronkulousness" {
+		t.Errorf("Expected gronkulousness, got %s", got)
+	}
+	if got := conf.campaignFor("#elsewhere"); got != "" {
+		t.Errorf("Expected no campaign, got %s", got)
+	}
+}
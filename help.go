@@ -5,7 +5,7 @@ import (
 )
 
 // ZW is a zero-width space
-const ZW = string(0x200b)
+const ZW = string(rune(0x200b))
 
 func genHelpText(conf *Config) string {
 	helpText := fmt.Sprintf("  ~~ dungeonbot %s ~~\n", VERSION)
@@ -14,9 +14,128 @@ func genHelpText(conf *Config) string {
 An assistance bot for tabletop RPG games being played through IRC.
 
     !roll NdN[+-N]
-        Roll dice or a die with optional modifier. Eg: !roll 1d20+4
-        The die type must be one of [d4|d6|d8|d10|d12|d20|d100]
-        and number less than 100 in quantity.
+        Roll dice or a die with optional modifiers, which may be
+        negative or repeated. Eg: !roll 1d20+4, !roll 1d20-1+2
+        Dice and numbers may be added, subtracted, multiplied and
+        grouped with parentheses. Eg: !roll (1d8+2)*2+3d6
+        Keep or drop the highest or lowest dice with kh/kl/dh/dl,
+        which are shown in parentheses. Eg: !roll 4d6kh3, !roll 2d20kl1
+        Explode on the max face with ! or compound with !!, reroll with
+        r (until) or ro (once), and raise low dice with min.
+        Eg: !roll 1d6!, !roll 2d6r<3, !roll 2d6min2
+        Count successes instead of summing by giving a target, with
+        f to subtract failures (botches) and gl to watch for glitches.
+        Eg: !roll 8d10>=8, !roll 8d10>=8!f1, !roll 12d6>=5gl
+        Fudge dice are rolled as dF and read off the FATE ladder.
+        Eg: !roll 4dF+2
+        Natural 20s and 1s on a d20 are called out. Change the crit
+        range with cs and the fumble range with cf. Eg: !roll 1d20cs>=19
+        Roll against a target number with vs or dc, adding pf2 for
+        Pathfinder 2e degrees of success. Eg: !roll 1d20+5 vs 15,
+        !roll 1d20+7 dc20 pf2
+        Anything after the roll, or after a #, labels it.
+        Eg: !roll 2d6+3 fire damage, !roll 1d20+5 # attack on the goblin
+        Roll the same thing several times with Nx or repeat N.
+        Eg: !roll 6x 4d6kh3, !roll 1d20+7 vs 15 repeat 3
+`
+	helpText += fmt.Sprintf("        %s\n", conf.dice.describe())
+	helpText += `
+    !sroll NdN[+-N]
+    !gmroll NdN[+-N]
+        Roll in secret. The result is sent by notice only to you and
+        the channel's GMs, and the channel is told you rolled.
+
+    !history [$NICK] [N]
+        Show the last N rolls made in the channel, up to 10,
+        optionally only those made by $NICK. Eg: !history gbmor 3
+
+    !rollstats [$NICK] [dN]
+        Show how many of each die $NICK (or everyone) has rolled, the
+        average roll against the expected average, and a histogram
+        of d20 faces. Eg: !rollstats gbmor d20
+
+    !rollstats campaign $NAME
+        Rank the players of campaign $NAME by how lucky they've been
+
+    !coc $VALUE [+N|-N] [hard|extreme]
+    !skill $SKILL $VALUE [+N|-N] [hard|extreme]
+        Make a Call of Cthulhu percentile check against $VALUE, with
+        N bonus (+) or penalty (-) dice, up to 2. Reports a regular,
+        hard, extreme or critical success, a failure or a fumble.
+        Eg: !skill Spot Hidden 65 +1
+
+    !pbta +N [$MOVE]
+        Roll 2d6+N for a Powered by the Apocalypse move: a strong hit
+        on 10+, a weak hit on 7-9 and a miss on 6-. Eg: !pbta +2 go aggro
+
+    !blades N [$ACTION]
+        Roll a Blades in the Dark pool of N d6 and take the highest:
+        a critical on two or more 6s, full success on a 6, partial on
+        4-5 and a bad outcome on 1-3. With 0 dice, roll 2 and take the
+        lowest. Eg: !blades 3 skirmish
+
+    !ironsworn action +N [$MOMENTUM]
+    !ironsworn progress N
+        Make an Ironsworn or Starforged roll against two d10 challenge
+        dice: d6+N for an action, or just N for progress. Reports a
+        strong hit, weak hit or miss, any match, and whether burning
+        momentum would do better. Without $MOMENTUM it's taken from a
+        "momentum: N" in your character sheet, if you have one.
+        Eg: !ironsworn action +2 5 face danger
+
+    !sw dN[+-N] [tn N] [extra] [$TRAIT]
+        Make a Savage Worlds trait roll: an acing dN and an acing d6
+        wild die, keeping the higher, against TN 4 or the TN given.
+        Reports success and raises, or a critical failure on double
+        1s. extra rolls without the wild die. Eg: !sw d8+1 shooting
+
+    !nd [N]a [N]p [N]b [N]d [N]c [N]s [N]f
+        Roll Genesys or Star Wars narrative dice: ability,
+        proficiency, boost, difficulty, challenge, setback and force.
+        Faces show s success, a advantage, T triumph, f failure,
+        t threat, D despair, l light and d dark. Reports the net
+        successes and advantage, triumphs and despairs. Words after
+        the dice are a label. Eg: !nd 2a 1p 2d 1c shoot a trooper
+
+    !macro set [campaign $NAME] $MACRO $ROLL
+        Save $ROLL as $MACRO, to roll with !roll $MACRO or !$MACRO.
+        Chain rolls and other macros with ';'. Macros saved for a
+        campaign are used in the channels it's played in.
+        Eg: !macro set attack 1d20+7 # longsword
+            !macro set fullattack attack; attack; 2d6+4
+
+    !macro del [campaign $NAME] $MACRO
+        Delete the macro $MACRO
+
+    !macro list
+        List your macros
+
+    !commit
+        Seal the channel's rolls for play-by-post. dungeonbot publishes
+        the sha256 of a secret seed, and every roll until !reveal is
+        drawn from it. Only the channel's GMs may seal, if it has any.
+
+    !reveal
+        Reveal the seed behind the channel's seal
+
+    !verify [N]
+        Check seal N, or the latest one revealed, matches its
+        commitment and that all its rolls follow from its seed. The
+        dice for the Nth roll command under a seal come from the first
+        8 bytes, big-endian, of sha256("$SEED:N:0"), sha256("$SEED:N:1")
+        and so on, taken modulo the die size after skipping any that
+        would bias the result.
+
+    !odds NdN[+-N] [vs N|dcN|>=N] [adv|dis]
+        Work out the chance of meeting a target, along with the
+        average and spread of the roll. adv and dis roll the d20
+        twice and keep the higher or lower. Eg: !odds 1d20+7 vs 15 adv
+        A trailing >=N is a target for the total, so wrap a pool in
+        parentheses to see its successes. Eg: !odds (8d10>=8)>=2
+
+    [[NdN]]
+        Roll inline anywhere in an ordinary message, taking anything
+        !roll does. Eg: Thorin swings at the orc [[1d20+5]] for [[1d8+3]]
 
     !add [campaign] $NAME
         Add a campaign notepad called $NAME
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IronswornRoll is an Ironsworn or Starforged action or progress
+// roll, made against two d10 challenge dice
+type IronswornRoll struct {
+	kind        string
+	label       string
+	stat        int
+	momentum    int
+	hasMomentum bool
+	action      int
+	challenge   []int
+}
+
+// an action score never beats a 10, and momentum runs from -6 to +10
+const (
+	maxIronswornScore = 10
+	minMomentum       = -6
+	maxMomentum       = 10
+)
+
+var momentumRegex = regexp.MustCompile(`(?i)\bmomentum\s*[:=]?\s*([+-]?\d+)`)
+
+// parseIronsworn reads "action +2 [momentum]" or "progress 7" for
+// !ironsworn, with anything else taken as a label
+func parseIronsworn(args []string) (*IronswornRoll, error) {
+	if len(args) == 0 {
+		return nil, errors.New("Missing roll. Eg: !ironsworn action +2 5")
+	}
+
+	is := &IronswornRoll{kind: strings.ToLower(args[0])}
+	if is.kind != "action" && is.kind != "progress" {
+		return nil, errors.New("Roll either action or progress. Eg: !ironsworn action +2 5")
+	}
+
+	var label, nums []string
+	for _, arg := range args[1:] {
+		if _, err := strconv.Atoi(arg); err == nil {
+			nums = append(nums, arg)
+			continue
+		}
+		if arg != "#" {
+			label = append(label, strings.TrimPrefix(arg, "#"))
+		}
+	}
+
+	if len(nums) == 0 {
+		return nil, errors.New("Missing stat. Eg: !ironsworn action +2 5")
+	}
+	is.stat, _ = strconv.Atoi(nums[0])
+	if is.kind == "progress" && (is.stat < 0 || is.stat > maxIronswornScore) {
+		return nil, fmt.Errorf("progress must be 0 to %d", maxIronswornScore)
+	}
+	if is.stat < -maxIronswornScore || is.stat > maxIronswornScore {
+		return nil, fmt.Errorf("stat must be -%d to +%d", maxIronswornScore, maxIronswornScore)
+	}
+
+	if len(nums) > 1 && is.kind == "action" {
+		momentum, _ := strconv.Atoi(nums[1])
+		if err := is.setMomentum(momentum); err != nil {
+			return nil, err
+		}
+	}
+
+	is.label = strings.Join(label, " ")
+	return is, nil
+}
+
+func (is *IronswornRoll) setMomentum(n int) error {
+	if n < minMomentum || n > maxMomentum {
+		return fmt.Errorf("momentum must be %d to +%d", minMomentum, maxMomentum)
+	}
+	is.momentum, is.hasMomentum = n, true
+	return nil
+}
+
+// useSheet takes momentum from a "momentum: 5" in the character's
+// notes, unless it was given with the roll
+func (is *IronswornRoll) useSheet(pc *PCRow) {
+	if is.kind != "action" || is.hasMomentum {
+		return
+	}
+	if match := momentumRegex.FindStringSubmatch(pc.notes); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		is.setMomentum(n)
+	}
+}
+
+// roll throws the action die, if there is one, and the challenge dice
+func (is *IronswornRoll) roll(r *Roller) {
+	is.action = 0
+	if is.kind == "action" {
+		is.action = r.getRoll(6)
+	}
+	is.challenge = []int{r.getRoll(10), r.getRoll(10)}
+}
+
+// cancelled reports whether negative momentum cancels the action die
+func (is *IronswornRoll) cancelled() bool {
+	return is.hasMomentum && is.momentum < 0 && -is.momentum == is.action
+}
+
+// score is the action die plus the stat, or the progress,
+// to a maximum of 10
+func (is *IronswornRoll) score() int {
+	score := is.stat
+	if is.kind == "action" && !is.cancelled() {
+		score += is.action
+	}
+	if score > maxIronswornScore {
+		score = maxIronswornScore
+	}
+	return score
+}
+
+// hits is how many challenge dice the score beats. Ties go to the
+// challenge dice.
+func hits(score int, challenge []int) int {
+	n := 0
+	for _, c := range challenge {
+		if score > c {
+			n++
+		}
+	}
+	return n
+}
+
+var ironswornOutcomes = []string{"miss", "weak hit", "strong hit"}
+var ironswornColors = []string{ircRed, ircOrange, ircGreen}
+
+func (is *IronswornRoll) outcome(format bool) string {
+	n := hits(is.score(), is.challenge)
+	return highlight(ironswornOutcomes[n], ironswornColors[n], format)
+}
+
+// report describes the roll for the channel, noting a match and
+// whether burning momentum would do better
+func (is *IronswornRoll) report(nick string, format bool) string {
+	what := fmt.Sprintf("progress %d", is.stat)
+	if is.kind == "action" {
+		what = fmt.Sprintf("action %+d", is.stat)
+	}
+	if is.label != "" {
+		what = fmt.Sprintf("%s (%s)", is.label, what)
+	}
+
+	rolled := fmt.Sprintf("%d", is.score())
+	if is.kind == "action" {
+		action := fmt.Sprintf("%d", is.action)
+		if is.cancelled() {
+			action = fmt.Sprintf("(%d)", is.action)
+		}
+		rolled = fmt.Sprintf("%s%+d = %d", action, is.stat, is.score())
+	}
+
+	out := fmt.Sprintf("%s rolls %s: %s vs %d %d,  %s", nick, what, rolled, is.challenge[0], is.challenge[1], is.outcome(format))
+
+	if is.challenge[0] == is.challenge[1] {
+		out += ",  match!"
+	}
+	if is.cancelled() {
+		out += fmt.Sprintf(",  momentum %d cancels the action die", is.momentum)
+	}
+	if is.kind == "action" && is.hasMomentum && is.momentum > 0 {
+		if burnt := hits(is.momentum, is.challenge); burnt > hits(is.score(), is.challenge) {
+			out += fmt.Sprintf(",  burn momentum %d for a %s", is.momentum, ironswornOutcomes[burnt])
+		}
+	}
+
+	return out
+}
+
+// historyRow records the roll for the roll log
+func (is *IronswornRoll) historyRow(nick, channel string) RollRow {
+	expr := fmt.Sprintf("ironsworn progress %d", is.stat)
+	dice := fmt.Sprintf("d10:%d,%d", is.challenge[0], is.challenge[1])
+	if is.kind == "action" {
+		expr = fmt.Sprintf("ironsworn action %+d", is.stat)
+		dice = fmt.Sprintf("d6:%d %s", is.action, dice)
+	}
+	// negative momentum can cancel the action die, so
+	// it's needed to roll the same score again
+	if is.kind == "action" && is.hasMomentum {
+		expr += fmt.Sprintf(" %d", is.momentum)
+	}
+
+	return RollRow{
+		nick:    strings.ToLower(nick),
+		channel: strings.ToLower(channel),
+		expr:    expr,
+		label:   is.label,
+		dice:    dice,
+		total:   is.score(),
+		time:    time.Now().UTC(),
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var ironswornCases = []struct {
+	args    string
+	sheet   string
+	faces   []int
+	want    string
+	wantErr bool
+}{
+	{args: "action +2", faces: []int{4, 3, 8}, want: "gbmor rolls action +2: 4+2 = 6 vs 3 8,  weak hit"},
+	{args: "action +3 face danger", faces: []int{6, 2, 5}, want: "gbmor rolls face danger (action +3): 6+3 = 9 vs 2 5,  strong hit"},
+	{args: "action +1", faces: []int{2, 3, 3}, want: "gbmor rolls action +1: 2+1 = 3 vs 3 3,  miss,  match!"},
+	{args: "action +4", faces: []int{6, 9, 10}, want: "gbmor rolls action +4: 6+4 = 10 vs 9 10,  weak hit"},
+	{args: "action +2 8", faces: []int{1, 5, 7}, want: "gbmor rolls action +2: 1+2 = 3 vs 5 7,  miss,  burn momentum 8 for a strong hit"},
+	{args: "action +2 -3", faces: []int{3, 1, 4}, want: "gbmor rolls action +2: (3)+2 = 2 vs 1 4,  weak hit,  momentum -3 cancels the action die"},
+	{args: "action +2", sheet: "Kira\nmomentum: 6\nhealth 5", faces: []int{2, 5, 6}, want: "gbmor rolls action +2: 2+2 = 4 vs 5 6,  miss,  burn momentum 6 for a weak hit"},
+	{args: "action +2 2", sheet: "momentum: 6", faces: []int{2, 5, 6}, want: "gbmor rolls action +2: 2+2 = 4 vs 5 6,  miss"},
+	{args: "progress 7 # slay the wyrm", faces: []int{6, 7}, want: "gbmor rolls slay the wyrm (progress 7): 7 vs 6 7,  weak hit"},
+	{args: "", wantErr: true},
+	{args: "attack +2", wantErr: true},
+	{args: "action", wantErr: true},
+	{args: "action +2 11", wantErr: true},
+	{args: "progress 11", wantErr: true},
+}
+
+func Test_IronswornRoll(t *testing.T) {
+	for _, tt := range ironswornCases {
+		t.Run(tt.args, func(t *testing.T) {
+			is, err := parseIronsworn(strings.Fields(tt.args))
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got %v", is)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if tt.sheet != "" {
+				is.useSheet(&PCRow{notes: tt.sheet})
+			}
+			is.roll(fixedRoller(tt.faces...))
+			if got := is.report("gbmor", false); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	is, _ := parseIronsworn([]string{"action", "+2"})
+	is.roll(fixedRoller(4, 3, 8))
+	row := is.historyRow("gbmor", "#ttrpg")
+	if row.expr != "ironsworn action +2" || row.dice != "d6:4 d10:3,8" || row.total != 6 {
+		t.Errorf("Unexpected history row: %v", row)
+	}
+}
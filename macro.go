@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Macro is a roll saved under a name by a nick, for
+// all their games or for a single campaign
+type Macro struct {
+	nick     string
+	campaign string
+	name     string
+	body     string
+}
+
+var macroNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// how deeply macros may call other macros, and the most
+// rolls one macro may expand to
+const (
+	maxMacroDepth = 5
+	maxMacroRolls = 10
+)
+
+// dungeonbot's own commands, which macros can't be named after
+var reservedNames = []string{
+	"add", "adduser", "append", "blades", "botlist", "campaign", "clear", "coc", "commit",
+	"delete", "gmroll", "help", "history", "ironsworn", "macro", "nd", "odds", "pbta", "reveal",
+	"roll", "rollstats", "skill", "sroll", "sw", "verify",
+}
+
+// String shows the macro for !macro list
+func (m Macro) String() string {
+	if m.campaign != "" {
+		return fmt.Sprintf("[%s] %s: %s", m.campaign, m.name, m.body)
+	}
+	return fmt.Sprintf("%s: %s", m.name, m.body)
+}
+
+// isReserved reports whether name is one of dungeonbot's commands
+func isReserved(name string) bool {
+	for _, reserved := range reservedNames {
+		if name == reserved {
+			return true
+		}
+	}
+	return false
+}
+
+// validMacroName checks a macro name can't be mistaken
+// for a command or a roll
+func validMacroName(name string) error {
+	if !macroNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid macro name: %s", name)
+	}
+	if isReserved(name) {
+		return fmt.Errorf("%s is already a command", name)
+	}
+	if _, err := parseExpr(name); err == nil {
+		return fmt.Errorf("%s is already a roll", name)
+	}
+	return nil
+}
+
+// parseMacroArgs reads the optional "campaign $NAME" given to
+// !macro set and !macro del, returning the campaign and the rest
+func parseMacroArgs(args []string) (string, []string, error) {
+	if len(args) == 0 || !strings.EqualFold(args[0], "campaign") {
+		return "", args, nil
+	}
+	if len(args) < 2 {
+		return "", nil, errors.New("Missing campaign name")
+	}
+	return strings.ToLower(args[1]), args[2:], nil
+}
+
+// parseMacroSet reads "[campaign $NAME] name body" for !macro set
+func parseMacroSet(nick string, args []string) (*Macro, error) {
+	campaign, args, err := parseMacroArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 2 {
+		return nil, errors.New("Missing macro. Eg: !macro set attack 1d20+7 # longsword")
+	}
+
+	name := strings.ToLower(args[0])
+	if err := validMacroName(name); err != nil {
+		return nil, err
+	}
+
+	return &Macro{
+		nick:     strings.ToLower(nick),
+		campaign: campaign,
+		name:     name,
+		body:     strings.Join(args[1:], " "),
+	}, nil
+}
+
+// macroLookup maps each macro name to its body. A macro
+// for a campaign hides a nick's macro of the same name.
+func macroLookup(macros []Macro) map[string]string {
+	out := make(map[string]string)
+	for _, m := range macros {
+		if _, ok := out[m.name]; !ok || m.campaign != "" {
+			out[m.name] = m.body
+		}
+	}
+	return out
+}
+
+// check makes sure the macro expands to rolls that parse, given
+// the nick's other macros, without calling itself
+func (m *Macro) check(macros map[string]string) error {
+	lookup := map[string]string{m.name: m.body}
+	for name, body := range macros {
+		if name != m.name {
+			lookup[name] = body
+		}
+	}
+
+	parts, err := expandMacros(m.name, lookup)
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if _, err := parseRollRequest(part); err != nil {
+			return fmt.Errorf("%s: %s", part, err.Error())
+		}
+	}
+	return nil
+}
+
+// expandMacros splits a roll on ';' and replaces any part
+// naming a macro with that macro's rolls
+func expandMacros(s string, macros map[string]string) ([]string, error) {
+	out, err := expand(s, macros, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxMacroRolls {
+		return nil, fmt.Errorf("a macro can make at most %d rolls", maxMacroRolls)
+	}
+	return out, nil
+}
+
+func expand(s string, macros map[string]string, depth int) ([]string, error) {
+	var out []string
+
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		body, ok := macros[strings.ToLower(part)]
+		if !ok {
+			out = append(out, part)
+			continue
+		}
+		if depth == maxMacroDepth {
+			return nil, fmt.Errorf("macro %s nests too deeply", strings.ToLower(part))
+		}
+
+		parts, err := expand(body, macros, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, parts...)
+
+		// stops a macro that calls itself from growing forever
+		if len(out) > maxMacroRolls {
+			return out, nil
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testMacros = map[string]string{
+	"attack":     "1d20+7 # longsword",
+	"damage":     "1d8+4",
+	"fullattack": "attack; damage; attack; 2d6+4",
+	"loop":       "loop",
+	"twice":      "fullattack; fullattack",
+	"thrice":     "twice; fullattack",
+}
+
+var expandMacrosCases = []struct {
+	raw     string
+	want    []string
+	wantErr bool
+}{
+	{raw: "1d20", want: []string{"1d20"}},
+	{raw: "Attack", want: []string{"1d20+7 # longsword"}},
+	{raw: "attack; 1d6 ;", want: []string{"1d20+7 # longsword", "1d6"}},
+	{raw: "fullattack", want: []string{"1d20+7 # longsword", "1d8+4", "1d20+7 # longsword", "2d6+4"}},
+	{raw: "twice", want: []string{"1d20+7 # longsword", "1d8+4", "1d20+7 # longsword", "2d6+4", "1d20+7 # longsword", "1d8+4", "1d20+7 # longsword", "2d6+4"}},
+	{raw: "thrice", wantErr: true},
+	{raw: "loop", wantErr: true},
+}
+
+func Test_expandMacros(t *testing.T) {
+	for _, tt := range expandMacrosCases {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := expandMacros(tt.raw, testMacros)
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got %v", got)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+var parseMacroSetCases = []struct {
+	args    []string
+	want    *Macro
+	wantErr bool
+}{
+	{args: []string{"Attack", "1d20+7", "#", "longsword"}, want: &Macro{nick: "gbmor", name: "attack", body: "1d20+7 # longsword"}},
+	{args: []string{"campaign", "Gronk", "attack", "1d20+5"}, want: &Macro{nick: "gbmor", campaign: "gronk", name: "attack", body: "1d20+5"}},
+	{args: []string{"attack"}, wantErr: true},
+	{args: []string{"campaign"}, wantErr: true},
+	{args: []string{"roll", "1d20"}, wantErr: true},
+	{args: []string{"d20", "1d20"}, wantErr: true},
+	{args: []string{"4dF", "1d20"}, wantErr: true},
+	{args: []string{"sneak!", "1d20"}, wantErr: true},
+}
+
+func Test_parseMacroSet(t *testing.T) {
+	for _, tt := range parseMacroSetCases {
+		t.Run(tt.args[0], func(t *testing.T) {
+			got, err := parseMacroSet("GBMor", tt.args)
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got %v", got)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_Macro_check(t *testing.T) {
+	ok := &Macro{name: "flurry", body: "attack; attack; 1d4 # dagger"}
+	if err := ok.check(testMacros); err != nil {
+		t.Errorf("Got unexpected error: %s", err.Error())
+	}
+
+	for _, m := range []*Macro{
+		{name: "attack", body: "attack"},
+		{name: "broken", body: "attack; ffff"},
+		{name: "damage", body: "fullattack"},
+	} {
+		if err := m.check(testMacros); err == nil {
+			t.Errorf("Expected %s: %s to be refused", m.name, m.body)
+		}
+	}
+}
+
+func Test_macroLookup(t *testing.T) {
+	macros := []Macro{
+		{nick: "gbmor", campaign: "gronk", name: "attack", body: "1d20+5"},
+		{nick: "gbmor", name: "attack", body: "1d20+7"},
+		{nick: "gbmor", name: "damage", body: "1d8"},
+	}
+
+	want := map[string]string{"attack": "1d20+5", "damage": "1d8"}
+	if got := macroLookup(macros); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if got := macros[0].String(); got != "[gronk] attack: 1d20+5" {
+		t.Errorf("Unexpected macro string: %s", got)
+	}
+}
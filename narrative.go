@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NarrativeRoll is a pool of Genesys or Star Wars narrative dice,
+// whose faces carry symbols rather than numbers
+type NarrativeRoll struct {
+	label string
+	pool  []int
+	rolls [][]int
+}
+
+// narrativeDie is one kind of narrative die and its faces. Each face
+// is a string of symbols: s success, a advantage, T triumph, f failure,
+// t threat, D despair, l light side and d dark side. "-" is blank.
+type narrativeDie struct {
+	name  string
+	code  string
+	faces []string
+}
+
+// in the order they're shown, good dice first
+var narrativeDice = []narrativeDie{
+	{name: "proficiency", code: "p", faces: []string{"-", "s", "s", "ss", "ss", "a", "sa", "sa", "sa", "aa", "aa", "T"}},
+	{name: "ability", code: "a", faces: []string{"-", "s", "s", "ss", "a", "a", "sa", "aa"}},
+	{name: "boost", code: "b", faces: []string{"-", "-", "s", "sa", "aa", "a"}},
+	{name: "challenge", code: "c", faces: []string{"-", "f", "f", "ff", "ff", "t", "t", "ft", "ft", "tt", "tt", "D"}},
+	{name: "difficulty", code: "d", faces: []string{"-", "f", "ff", "t", "t", "t", "tt", "ft"}},
+	{name: "setback", code: "s", faces: []string{"-", "-", "f", "f", "t", "t"}},
+	{name: "force", code: "f", faces: []string{"d", "d", "d", "d", "d", "d", "dd", "l", "l", "ll", "ll", "ll"}},
+}
+
+// force dice come last, and don't count towards success
+var forceDie = len(narrativeDice) - 1
+
+// the most narrative dice one !nd will roll
+const maxNarrativeDice = 20
+
+var narrativeRegex = regexp.MustCompile(`(?i)^(\d*)([a-z]+)$`)
+
+// parseNarrative reads a pool like "2a 1p 2d 1c" for !nd. Each die can
+// be given by its letter or its name. The dice end at the first word
+// that isn't one, and the rest is taken as a label.
+func parseNarrative(args []string) (*NarrativeRoll, error) {
+	n := &NarrativeRoll{pool: make([]int, len(narrativeDice))}
+	var label []string
+	total := 0
+
+	// die names are common words, so a label after '#' is left alone
+	rest := ""
+	joined := strings.Join(args, " ")
+	if i := strings.Index(joined, "#"); i >= 0 {
+		joined, rest = joined[:i], strings.TrimSpace(joined[i+1:])
+	}
+
+	words := strings.Fields(joined)
+	for i, arg := range words {
+		match := narrativeRegex.FindStringSubmatch(arg)
+		kind := -1
+		if match != nil {
+			kind = narrativeKind(strings.ToLower(match[2]))
+		}
+		if kind < 0 {
+			label = words[i:]
+			break
+		}
+
+		count := 1
+		if match[1] != "" {
+			count, _ = strconv.Atoi(match[1])
+		}
+		if count > maxNarrativeDice {
+			return nil, fmt.Errorf("no more than %d narrative dice at once", maxNarrativeDice)
+		}
+		n.pool[kind] += count
+		total += count
+	}
+
+	if total == 0 {
+		return nil, errors.New("Missing dice. Eg: !nd 2a 1p 2d 1c")
+	}
+	if total > maxNarrativeDice {
+		return nil, fmt.Errorf("no more than %d narrative dice at once", maxNarrativeDice)
+	}
+
+	if rest != "" {
+		label = append(label, rest)
+	}
+	n.label = strings.Join(label, " ")
+	return n, nil
+}
+
+// narrativeKind finds a die by its letter or name, allowing a plural
+func narrativeKind(s string) int {
+	for i, die := range narrativeDice {
+		if s == die.code || s == die.name || s == die.name+"s" || (strings.HasSuffix(die.name, "y") && s == strings.TrimSuffix(die.name, "y")+"ies") {
+			return i
+		}
+	}
+	return -1
+}
+
+// roll throws the pool, keeping which face of each die came up
+func (n *NarrativeRoll) roll(r *Roller) {
+	n.rolls = make([][]int, len(narrativeDice))
+	for kind, count := range n.pool {
+		for i := 0; i < count; i++ {
+			n.rolls[kind] = append(n.rolls[kind], r.getRoll(len(narrativeDice[kind].faces)))
+		}
+	}
+}
+
+// faces is the symbols rolled on each die of a kind
+func (n *NarrativeRoll) faces(kind int) []string {
+	out := make([]string, 0, len(n.rolls[kind]))
+	for _, roll := range n.rolls[kind] {
+		out = append(out, narrativeDice[kind].faces[roll-1])
+	}
+	return out
+}
+
+// tally counts every symbol rolled
+func (n *NarrativeRoll) tally() map[rune]int {
+	out := make(map[rune]int)
+	for kind := range n.rolls {
+		for _, face := range n.faces(kind) {
+			for _, symbol := range face {
+				out[symbol]++
+			}
+		}
+	}
+	return out
+}
+
+// plural names a count of something, like "2 successes"
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, one)
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+// net cancels successes against failures and advantage against threat.
+// Triumphs count as successes and despairs as failures, but are still
+// reported on their own.
+func (n *NarrativeRoll) net() (int, int) {
+	t := n.tally()
+	return t['s'] + t['T'] - t['f'] - t['D'], t['a'] - t['t']
+}
+
+// outcome describes the net result, succeeding on at least one net
+// success. A pool of only force dice just counts the pips.
+func (n *NarrativeRoll) outcome(format bool) string {
+	t := n.tally()
+	var parts []string
+
+	if n.pool[forceDie] < sum(n.pool) {
+		successes, advantage := n.net()
+		switch {
+		case successes > 0:
+			parts = append(parts, highlight("success", ircGreen, format), plural(successes, "success", "successes"))
+		case successes < 0:
+			parts = append(parts, highlight("failure", ircRed, format), plural(-successes, "failure", "failures"))
+		default:
+			parts = append(parts, highlight("failure", ircRed, format))
+		}
+
+		switch {
+		case advantage > 0:
+			parts = append(parts, plural(advantage, "advantage", "advantage"))
+		case advantage < 0:
+			parts = append(parts, plural(-advantage, "threat", "threat"))
+		}
+
+		if t['T'] > 0 {
+			parts = append(parts, plural(t['T'], "triumph", "triumphs"))
+		}
+		if t['D'] > 0 {
+			parts = append(parts, plural(t['D'], "despair", "despairs"))
+		}
+	}
+
+	if n.pool[forceDie] > 0 {
+		parts = append(parts, fmt.Sprintf("%d light, %d dark", t['l'], t['d']))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// String lists the pool as it would be given to !nd
+func (n *NarrativeRoll) String() string {
+	var out []string
+	for kind, count := range n.pool {
+		if count > 0 {
+			out = append(out, fmt.Sprintf("%d%s", count, narrativeDice[kind].code))
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// report describes the roll for the channel
+func (n *NarrativeRoll) report(nick string, format bool) string {
+	what := n.String()
+	if n.label != "" {
+		what = fmt.Sprintf("%s (%s)", n.label, what)
+	}
+
+	var rolled []string
+	for kind := range n.rolls {
+		if faces := n.faces(kind); len(faces) > 0 {
+			rolled = append(rolled, fmt.Sprintf("%s %s", narrativeDice[kind].name, strings.Join(faces, " ")))
+		}
+	}
+
+	return fmt.Sprintf("%s rolls %s: %s,  %s", nick, what, strings.Join(rolled, ", "), n.outcome(format))
+}
+
+// historyRow records the roll for the roll log. Faces are logged by
+// their place on the die under the die's code, like "nd-p:12", which
+// !rollstats leaves out of its numbered dice. The total is the net
+// successes.
+func (n *NarrativeRoll) historyRow(nick, channel string) RollRow {
+	var dice []string
+	for kind, rolls := range n.rolls {
+		if len(rolls) == 0 {
+			continue
+		}
+		places := make([]string, 0, len(rolls))
+		for _, roll := range rolls {
+			places = append(places, strconv.Itoa(roll))
+		}
+		dice = append(dice, fmt.Sprintf("nd-%s:%s", narrativeDice[kind].code, strings.Join(places, ",")))
+	}
+
+	successes, _ := n.net()
+	return RollRow{
+		nick:    strings.ToLower(nick),
+		channel: strings.ToLower(channel),
+		expr:    "nd " + n.String(),
+		label:   n.label,
+		dice:    strings.Join(dice, " "),
+		total:   successes,
+		time:    time.Now().UTC(),
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var parseNarrativeCases = []struct {
+	args    string
+	pool    string
+	label   string
+	wantErr bool
+}{
+	{args: "2a 1p 2d 1c", pool: "1p 2a 1c 2d"},
+	{args: "a a p boost 2setback", pool: "1p 2a 1b 2s"},
+	{args: "3abilities 2difficulties # persuade the guard", pool: "3a 2d", label: "persuade the guard"},
+	{args: "2F pilot", pool: "2f", label: "pilot"},
+	{args: "2a 1d shoot a trooper", pool: "2a 1d", label: "shoot a trooper"},
+	{args: "1a pilot a ship # evade", pool: "1a", label: "pilot a ship evade"},
+	{args: "pilot", wantErr: true},
+	{args: "pilot 2a", wantErr: true},
+	{args: "21a", wantErr: true},
+	{args: "10a 10p 1d", wantErr: true},
+}
+
+func Test_parseNarrative(t *testing.T) {
+	for _, tt := range parseNarrativeCases {
+		t.Run(tt.args, func(t *testing.T) {
+			n, err := parseNarrative(strings.Fields(tt.args))
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got %v", n)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if n.String() != tt.pool {
+				t.Errorf("Expected pool %s, got %s", tt.pool, n.String())
+			}
+			if n.label != tt.label {
+				t.Errorf("Expected label %s, got %s", tt.label, n.label)
+			}
+		})
+	}
+}
+
+var narrativeRollCases = []struct {
+	args  string
+	faces []int
+	want  string
+}{
+	// dice are rolled proficiency, ability, boost, challenge,
+	// difficulty, setback then force
+	{args: "2a 1p 2d 1c", faces: []int{12, 4, 8, 8, 2, 1}, want: "gbmor rolls 1p 2a 1c 2d: proficiency T, ability ss aa, challenge ft, difficulty f -,  success, 1 success, 1 advantage, 1 triumph"},
+	{args: "1a 1c", faces: []int{1, 12}, want: "gbmor rolls 1a 1c: ability -, challenge D,  failure, 1 failure, 1 despair"},
+	{args: "1a 1d 1s", faces: []int{2, 2, 5}, want: "gbmor rolls 1a 1d 1s: ability s, difficulty f, setback t,  failure, 1 threat"},
+	{args: "1b 1f", faces: []int{4, 8}, want: "gbmor rolls 1b 1f: boost sa, force l,  success, 1 success, 1 advantage, 1 light, 0 dark"},
+	{args: "2f # use the force", faces: []int{7, 12}, want: "gbmor rolls use the force (2f): force dd ll,  2 light, 2 dark"},
+}
+
+func Test_NarrativeRoll(t *testing.T) {
+	for _, tt := range narrativeRollCases {
+		t.Run(tt.args, func(t *testing.T) {
+			n, err := parseNarrative(strings.Fields(tt.args))
+			if err != nil {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+
+			n.roll(fixedRoller(tt.faces...))
+			if got := n.report("gbmor", false); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	n, _ := parseNarrative(strings.Fields("2a 1p 2d 1c"))
+	n.roll(fixedRoller(12, 4, 8, 8, 2, 1))
+	row := n.historyRow("gbmor", "#genesys")
+	if row.expr != "nd 1p 2a 1c 2d" || row.dice != "nd-p:12 nd-a:4,8 nd-c:8 nd-d:2,1" || row.total != 1 {
+		t.Errorf("Unexpected history row: %v", row)
+	}
+
+	// narrative faces aren't numbers, so !rollstats doesn't count them
+	stats := tallyDice([]string{row.dice + " d20:17"})
+	if len(stats) != 1 || stats[20] == nil || stats[20].count != 1 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
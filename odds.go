@@ -0,0 +1,461 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dist is a probability distribution over whole-number totals,
+// where p[i] is the chance of rolling min+i
+type dist struct {
+	min int
+	p   []float64
+}
+
+// Odds is a parsed !odds request
+type Odds struct {
+	expr   string
+	node   *exprNode
+	target *comparePoint
+	adv    int
+}
+
+// past this many outcomes, or steps to work them out, we simulate
+// instead of working it out exactly
+const (
+	maxDistWidth  = 1 << 16
+	maxEnumerated = 1 << 20
+	maxSteps      = 1 << 24
+	simulations   = 20000
+)
+
+var errTooComplex = errors.New("too complex to work out exactly")
+
+var oddsTargetRegex = regexp.MustCompile(`^(.*?)\s*(<=|>=|<|>|=)\s*(-?\d+)\s*$`)
+
+// parseOdds reads an !odds request: a roll with an optional target for
+// its total, given as "vs 15", "dc15" or a trailing comparison like
+// ">=12", and
+// optionally "adv" or "dis" to roll the first d20 with advantage or
+// disadvantage
+func parseOdds(s string) (*Odds, error) {
+	odds := &Odds{}
+
+	var words []string
+	for _, word := range strings.Fields(s) {
+		switch strings.ToLower(word) {
+		case "adv", "advantage":
+			odds.adv = 1
+		case "dis", "disadvantage":
+			odds.adv = -1
+		default:
+			words = append(words, word)
+		}
+	}
+
+	expr, check, err := parseCheck(strings.Join(words, " "))
+	if err != nil {
+		return nil, err
+	}
+	if check != nil {
+		odds.target = &comparePoint{op: ">=", value: check.dc}
+	}
+
+	// a trailing comparison is always a target for the total,
+	// so a pool has to be wrapped in parentheses. Eg: (8d10>=8)
+	if match := oddsTargetRegex.FindStringSubmatch(expr); match != nil && odds.target == nil {
+		if _, err := parseExpr(match[1]); err == nil {
+			n, err := strconv.Atoi(match[3])
+			if err != nil || n > maxLiteral || n < -maxLiteral {
+				return nil, fmt.Errorf("unable to parse target number: %s", match[3])
+			}
+			expr, odds.target = match[1], &comparePoint{op: match[2], value: n}
+		}
+	}
+
+	node, err := parseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	odds.expr, odds.node = expr, node
+
+	if odds.adv != 0 {
+		found := false
+		for _, term := range node.diceTerms() {
+			if term.sides == 20 && term.quantity == 1 && term.keep == 0 {
+				term.quantity, term.keep, term.keepHigh = 2, 1, odds.adv > 0
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("advantage needs a single d20 to apply to")
+		}
+	}
+
+	return odds, nil
+}
+
+// within checks the dice are ones !roll would accept
+func (o *Odds) within(limits DiceLimits) error {
+	diceNum := 0
+	for _, term := range o.node.diceTerms() {
+		if !term.fudge && !limits.validSides(term.sides) {
+			return fmt.Errorf("invalid die type: d%d", term.sides)
+		}
+		diceNum += term.quantity
+	}
+	if diceNum > limits.maxDice {
+		return errors.New("too many dice jfc")
+	}
+	return nil
+}
+
+// distribution works out the chance of every total. If that's too
+// complex it's estimated by rolling many times, and exact is false.
+func (o *Odds) distribution() (dist, bool, error) {
+	if d, err := o.node.dist(); err == nil {
+		return d, true, nil
+	}
+
+	if _, _, err := o.node.bounds(); err != nil {
+		return dist{}, false, err
+	}
+
+	r := newSeededRoller(time.Now().UnixNano())
+	counts := make(map[int]int)
+	lo, hi := math.MaxInt32, math.MinInt32
+	for i := 0; i < simulations; i++ {
+		total := o.node.eval(r)
+		counts[total]++
+		if total < lo {
+			lo = total
+		}
+		if total > hi {
+			hi = total
+		}
+	}
+
+	if hi-lo+1 > maxDistWidth {
+		return dist{}, false, errTooComplex
+	}
+
+	d := dist{min: lo, p: make([]float64, hi-lo+1)}
+	for total, n := range counts {
+		d.p[total-lo] = float64(n) / simulations
+	}
+	return d, false, nil
+}
+
+// report describes the odds of the roll
+func (o *Odds) report() (string, error) {
+	d, exact, err := o.distribution()
+	if err != nil {
+		return "", err
+	}
+
+	what := o.expr
+	if o.adv > 0 {
+		what += " with advantage"
+	} else if o.adv < 0 {
+		what += " with disadvantage"
+	}
+
+	var out strings.Builder
+	out.WriteString(what)
+
+	if o.target != nil {
+		chance := 0.0
+		for i, p := range d.p {
+			if o.target.match(d.min + i) {
+				chance += p
+			}
+		}
+		out.WriteString(fmt.Sprintf(" %s: %.2f%% chance", o.target.compareString(), chance*100))
+	}
+
+	out.WriteString(fmt.Sprintf(",  mean %.2f,  10th/50th/90th percentile %d/%d/%d,  range %d to %d",
+		d.mean(), d.percentile(0.1), d.percentile(0.5), d.percentile(0.9), d.min, d.min+len(d.p)-1))
+
+	if !exact {
+		out.WriteString(fmt.Sprintf("  (estimated from %d rolls)", simulations))
+	}
+
+	return out.String(), nil
+}
+
+// compareString spells out the comparison, always with its operator
+func (c *comparePoint) compareString() string {
+	return fmt.Sprintf("%s %d", c.op, c.value)
+}
+
+func constDist(n int) dist {
+	return dist{min: n, p: []float64{1}}
+}
+
+func (d dist) max() int {
+	return d.min + len(d.p) - 1
+}
+
+func (d dist) mean() float64 {
+	out := 0.0
+	for i, p := range d.p {
+		out += float64(d.min+i) * p
+	}
+	return out
+}
+
+// percentile is the lowest total with at least q of
+// the outcomes at or below it
+func (d dist) percentile(q float64) int {
+	sum := 0.0
+	for i, p := range d.p {
+		sum += p
+		if sum >= q-1e-9 {
+			return d.min + i
+		}
+	}
+	return d.max()
+}
+
+// add convolves two distributions, giving the distribution of their sum
+func (d dist) add(o dist) (dist, error) {
+	width := len(d.p) + len(o.p) - 1
+	if width > maxDistWidth || len(d.p)*len(o.p) > maxSteps {
+		return dist{}, errTooComplex
+	}
+
+	out := dist{min: d.min + o.min, p: make([]float64, width)}
+	for i, p := range d.p {
+		if p == 0 {
+			continue
+		}
+		for j, q := range o.p {
+			out.p[i+j] += p * q
+		}
+	}
+	return out, nil
+}
+
+func (d dist) negate() dist {
+	out := dist{min: -d.max(), p: make([]float64, len(d.p))}
+	for i, p := range d.p {
+		out.p[len(d.p)-1-i] = p
+	}
+	return out
+}
+
+// multiply gives the distribution of the product of two distributions
+func (d dist) multiply(o dist) (dist, error) {
+	if len(d.p)*len(o.p) > maxEnumerated {
+		return dist{}, errTooComplex
+	}
+
+	probs := make(map[int]float64)
+	lo, hi := math.MaxInt32, math.MinInt32
+
+	for i, p := range d.p {
+		for j, q := range o.p {
+			if p == 0 || q == 0 {
+				continue
+			}
+			n := (d.min + i) * (o.min + j)
+			probs[n] += p * q
+			if n < lo {
+				lo = n
+			}
+			if n > hi {
+				hi = n
+			}
+		}
+	}
+
+	if hi-lo+1 > maxDistWidth {
+		return dist{}, errTooComplex
+	}
+
+	out := dist{min: lo, p: make([]float64, hi-lo+1)}
+	for n, p := range probs {
+		out.p[n-lo] = p
+	}
+	return out, nil
+}
+
+// dist works out the exact distribution of the expression's total
+func (n *exprNode) dist() (dist, error) {
+	switch n.kind {
+	case nodeConst:
+		return constDist(n.value), nil
+	case nodeDice:
+		return n.dice.dist()
+	case nodeGroup:
+		return n.left.dist()
+	case nodeNeg:
+		d, err := n.left.dist()
+		return d.negate(), err
+	}
+
+	left, err := n.left.dist()
+	if err != nil {
+		return dist{}, err
+	}
+	right, err := n.right.dist()
+	if err != nil {
+		return dist{}, err
+	}
+
+	switch n.op {
+	case '-':
+		return left.add(right.negate())
+	case '*':
+		return left.multiply(right)
+	}
+	return left.add(right)
+}
+
+// dieDist is the distribution of a single die's face, after
+// rerolls and raising low faces
+func (t *diceTerm) dieDist() dist {
+	if t.fudge {
+		return dist{min: -1, p: []float64{1.0 / 3, 1.0 / 3, 1.0 / 3}}
+	}
+
+	each := 1 / float64(t.sides)
+	raw := make([]float64, t.sides+1)
+	rerolled := 0.0
+	for face := 1; face <= t.sides; face++ {
+		if t.reroll != nil && t.reroll.match(face) {
+			rerolled += each
+			continue
+		}
+		raw[face] = each
+	}
+
+	for face := 1; face <= t.sides; face++ {
+		switch {
+		case t.reroll == nil:
+		case t.rerollOnce:
+			// a reroll can land on any face, even another reroll
+			raw[face] += rerolled * each
+		case !t.reroll.match(face):
+			// rerolling until it sticks spreads the rerolled
+			// chance over the faces that stick
+			raw[face] += rerolled * each / (1 - rerolled)
+		}
+	}
+
+	out := dist{min: 1, p: raw[1:]}
+	if t.floor > 1 {
+		for face := 1; face < t.floor; face++ {
+			out.p[t.floor-1] += out.p[face-1]
+			out.p[face-1] = 0
+		}
+	}
+	return out
+}
+
+// dist works out the exact distribution of the term's total. Exploding
+// dice, and keeping dice from a very large pool, are too complex.
+func (t *diceTerm) dist() (dist, error) {
+	if t.explode != nil {
+		return dist{}, errTooComplex
+	}
+
+	die := t.dieDist()
+
+	if t.success != nil {
+		if t.keep != 0 {
+			return dist{}, errTooComplex
+		}
+		// each die is worth -1, 0 or 1 success, or 0 or 1
+		// when failures aren't counted
+		var hit, miss float64
+		for i, p := range die.p {
+			switch face := die.min + i; {
+			case t.success.match(face):
+				hit += p
+			case t.failure != nil && t.failure.match(face):
+				miss += p
+			}
+		}
+		die = dist{min: -1, p: []float64{miss, 1 - hit - miss, hit}}
+		if t.failure == nil {
+			die = dist{min: 0, p: []float64{1 - hit, hit}}
+		}
+	}
+
+	if t.keep != 0 && t.keep < t.quantity {
+		return t.keptDist(die)
+	}
+
+	// each die added costs the width so far times the die's, so
+	// a big pool of big dice is slow long before it's too wide
+	if steps := t.quantity * len(die.p); steps*steps/2 > maxSteps {
+		return dist{}, errTooComplex
+	}
+
+	out := constDist(0)
+	for i := 0; i < t.quantity; i++ {
+		var err error
+		if out, err = out.add(die); err != nil {
+			return dist{}, err
+		}
+	}
+	return out, nil
+}
+
+// keptDist works through every way the dice can land to find
+// the distribution of the highest or lowest dice kept
+func (t *diceTerm) keptDist(die dist) (dist, error) {
+	if math.Pow(float64(len(die.p)), float64(t.quantity)) > maxEnumerated {
+		return dist{}, errTooComplex
+	}
+
+	lo, hi := die.min*t.keep, die.max()*t.keep
+	out := dist{min: lo, p: make([]float64, hi-lo+1)}
+
+	faces := make([]int, t.quantity)
+	sorted := make([]int, t.quantity)
+	for {
+		p := 1.0
+		for i, f := range faces {
+			p *= die.p[f]
+			sorted[i] = f
+		}
+
+		if p > 0 {
+			sort.Ints(sorted)
+			kept := sorted[:t.keep]
+			if t.keepHigh {
+				kept = sorted[t.quantity-t.keep:]
+			}
+			total := 0
+			for _, f := range kept {
+				total += die.min + f
+			}
+			out.p[total-lo] += p
+		}
+
+		// step to the next combination, like an odometer
+		i := 0
+		for ; i < t.quantity; i++ {
+			faces[i]++
+			if faces[i] < len(die.p) {
+				break
+			}
+			faces[i] = 0
+		}
+		if i == t.quantity {
+			break
+		}
+	}
+
+	return out, nil
+}
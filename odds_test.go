@@ -0,0 +1,152 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+var oddsCases = []struct {
+	raw     string
+	chance  float64
+	mean    float64
+	exact   bool
+	wantErr bool
+}{
+	{raw: "3d6>=12", chance: 0.375, mean: 10.5, exact: true},
+	{raw: "1d20+7 vs 15", chance: 0.65, mean: 17.5, exact: true},
+	{raw: "1d20+7 vs 15 adv", chance: 1 - 0.35*0.35, mean: 7 + 13.825, exact: true},
+	{raw: "1d20+7 dc15 dis", chance: 0.65 * 0.65, mean: 7 + 7.175, exact: true},
+	{raw: "2d6-1d4", chance: -1, mean: 4.5, exact: true},
+	{raw: "4d6kh3", chance: -1, mean: 15869.0 / 1296, exact: true},
+	{raw: "1d6r1", chance: -1, mean: 4, exact: true},
+	{raw: "1d6ro1", chance: -1, mean: 23.5 / 6, exact: true},
+	{raw: "1d6min3", chance: -1, mean: 4, exact: true},
+	{raw: "2d6*2", chance: -1, mean: 14, exact: true},
+	{raw: "4dF", chance: -1, mean: 0, exact: true},
+	{raw: "(6d10>=8)", chance: -1, mean: 1.8, exact: true},
+	{raw: "2d6>=5", chance: 30.0 / 36, mean: 7, exact: true},
+	{raw: "(8d10>=8) >= 2", chance: 1 - math.Pow(0.7, 8) - 8*0.3*math.Pow(0.7, 7), mean: 2.4, exact: true},
+	{raw: "1d6!", chance: -1, mean: 4.2, exact: false},
+	{raw: "2d6 adv", wantErr: true},
+	{raw: "ffff", wantErr: true},
+}
+
+func Test_odds(t *testing.T) {
+	for _, tt := range oddsCases {
+		t.Run(tt.raw, func(t *testing.T) {
+			odds, err := parseOdds(tt.raw)
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got nil: %s", tt.raw)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			d, exact, err := odds.distribution()
+			if err != nil {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if exact != tt.exact {
+				t.Errorf("Expected exact to be %v, got %v", tt.exact, exact)
+			}
+
+			sum := 0.0
+			for _, p := range d.p {
+				sum += p
+			}
+			if math.Abs(sum-1) > 1e-9 {
+				t.Errorf("Probabilities add up to %f", sum)
+			}
+
+			within := 1e-9
+			if !exact {
+				within = 0.1
+			}
+			if math.Abs(d.mean()-tt.mean) > within {
+				t.Errorf("Expected mean %f, got %f", tt.mean, d.mean())
+			}
+
+			if tt.chance < 0 {
+				if odds.target != nil {
+					t.Errorf("Didn't expect a target, got %s", odds.target.compareString())
+				}
+				return
+			}
+			chance := 0.0
+			for i, p := range d.p {
+				if odds.target.match(d.min + i) {
+					chance += p
+				}
+			}
+			if math.Abs(chance-tt.chance) > within {
+				t.Errorf("Expected chance %f, got %f", tt.chance, chance)
+			}
+		})
+	}
+}
+
+func Test_oddsReport(t *testing.T) {
+	odds, err := parseOdds("3d6 >= 12")
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+
+	want := "3d6 >= 12: 37.50% chance,  mean 10.50,  10th/50th/90th percentile 7/10/14,  range 3 to 18"
+	if got, _ := odds.report(); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	odds, err = parseOdds("1d20+7 vs 15 adv")
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+	if got, _ := odds.report(); !strings.HasPrefix(got, "1d20+7 with advantage >= 15: 87.75% chance") {
+		t.Errorf("Unexpected report: %s", got)
+	}
+
+	if err := odds.within(DiceLimits{maxSides: 12, maxDice: 10}); err == nil {
+		t.Errorf("Expected d20 to be refused")
+	}
+
+	// a pool that doesn't count failures can't go below 0
+	odds, err = parseOdds("(8d10>=8)")
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+	if got, _ := odds.report(); !strings.HasSuffix(got, "range 0 to 8") {
+		t.Errorf("Unexpected report: %s", got)
+	}
+}
+
+func Test_oddsTooLarge(t *testing.T) {
+	for _, raw := range []string{"1000000*1000000*1d6!", "1000000*1d6!"} {
+		odds, err := parseOdds(raw)
+		if err != nil {
+			t.Fatalf("Got unexpected error: %s", err.Error())
+		}
+		if out, err := odds.report(); err == nil {
+			t.Errorf("Expected an error for %s, got %s", raw, out)
+		}
+	}
+}
+
+func Test_oddsBigPools(t *testing.T) {
+	// too slow to work out exactly, so they're simulated
+	for _, raw := range []string{"30d1000*30d1000", "65d1000"} {
+		odds, err := parseOdds(raw)
+		if err != nil {
+			t.Fatalf("Got unexpected error: %s", err.Error())
+		}
+
+		start := time.Now()
+		odds.report()
+		if took := time.Since(start); took > time.Second {
+			t.Errorf("Took %s to work out %s", took, raw)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNum
+	tokIdent
+	tokOp
+)
+
+// token is a single lexeme of a dice expression
+type token struct {
+	kind tokenKind
+	text string
+	num  int
+}
+
+type nodeKind int
+
+const (
+	nodeConst nodeKind = iota
+	nodeDice
+	nodeNeg
+	nodeGroup
+	nodeBinary
+)
+
+// exprNode is a node in a parsed dice expression
+type exprNode struct {
+	kind  nodeKind
+	op    byte
+	value int
+	dice  *diceTerm
+	left  *exprNode
+	right *exprNode
+}
+
+// diceTerm is a single NdM group within an expression
+type diceTerm struct {
+	notation   string
+	quantity   int
+	sides      int
+	fudge      bool
+	keep       int
+	keepHigh   bool
+	explode    *comparePoint
+	compound   bool
+	reroll     *comparePoint
+	rerollOnce bool
+	floor      int
+	success    *comparePoint
+	failure    *comparePoint
+	glitch     bool
+	critHit    *comparePoint
+	critMiss   *comparePoint
+	rolls      []dieRoll
+	total      int
+	successes  int
+	failures   int
+	crits      []int
+	fumbles    []int
+}
+
+// comparePoint matches die faces for modifiers like r<3 or !>=5
+type comparePoint struct {
+	op    string
+	value int
+}
+
+type diceParser struct {
+	toks []token
+	pos  int
+}
+
+// the largest literal we'll accept in an expression, to
+// keep the arithmetic well away from overflowing
+const maxLiteral = 1000000
+
+func lexDice(s string) ([]token, error) {
+	var toks []token
+	runes := []rune(s)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			text := string(runes[i:j])
+			n, err := strconv.Atoi(text)
+			if err != nil || n > maxLiteral {
+				return nil, fmt.Errorf("number too large: %s", text)
+			}
+			toks = append(toks, token{kind: tokNum, text: text, num: n})
+			i = j
+		case unicode.IsLetter(r):
+			j := i
+			for j < len(runes) && unicode.IsLetter(runes[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: string(runes[i:j])})
+			i = j
+		case i+1 < len(runes) && isTwoCharOp(string(runes[i:i+2])):
+			toks = append(toks, token{kind: tokOp, text: string(runes[i : i+2])})
+			i += 2
+		case strings.ContainsRune("+-*()!<>=", r):
+			toks = append(toks, token{kind: tokOp, text: string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected '%c' in dice expression", r)
+		}
+	}
+
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+func isTwoCharOp(s string) bool {
+	return s == "!!" || s == "<=" || s == ">="
+}
+
+func parseExpr(s string) (*exprNode, error) {
+	toks, err := lexDice(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) == 1 {
+		return nil, errors.New("missing dice expression")
+	}
+
+	p := &diceParser{toks: toks}
+	node, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected '%s' in dice expression", tok.text)
+	}
+
+	return node, nil
+}
+
+func (p *diceParser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *diceParser) next() token {
+	tok := p.toks[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *diceParser) isOp(op string) bool {
+	tok := p.peek()
+	return tok.kind == tokOp && tok.text == op
+}
+
+// sum := product (('+'|'-') product)*
+func (p *diceParser) sum() (*exprNode, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("+") || p.isOp("-") {
+		op := p.next().text[0]
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprNode{kind: nodeBinary, op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// product := unary ('*' unary)*
+func (p *diceParser) product() (*exprNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("*") {
+		op := p.next().text[0]
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprNode{kind: nodeBinary, op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// unary := '-' unary | primary
+func (p *diceParser) unary() (*exprNode, error) {
+	if p.isOp("-") {
+		p.next()
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNode{kind: nodeNeg, left: inner}, nil
+	}
+
+	return p.primary()
+}
+
+// primary := '(' sum ')' | [N] 'd' M | [N] 'dF' | N
+func (p *diceParser) primary() (*exprNode, error) {
+	tok := p.peek()
+
+	switch {
+	case p.isOp("("):
+		p.next()
+		inner, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, errors.New("unbalanced parentheses")
+		}
+		p.next()
+		return &exprNode{kind: nodeGroup, left: inner}, nil
+
+	case tok.kind == tokNum:
+		p.next()
+		if isDiceIdent(p.peek()) {
+			return p.dice(tok.num)
+		}
+		return &exprNode{kind: nodeConst, value: tok.num}, nil
+
+	case isDiceIdent(tok):
+		return p.dice(1)
+
+	case tok.kind == tokEOF:
+		return nil, errors.New("unexpected end of dice expression")
+	}
+
+	return nil, fmt.Errorf("unexpected '%s' in dice expression", tok.text)
+}
+
+func isDiceIdent(tok token) bool {
+	return tok.kind == tokIdent && (strings.EqualFold(tok.text, "d") || strings.EqualFold(tok.text, "dF"))
+}
+
+// dice parses the 'd' M or 'dF' portion of a dice term
+func (p *diceParser) dice(quantity int) (*exprNode, error) {
+	fudge := strings.EqualFold(p.next().text, "dF")
+
+	if quantity < 1 {
+		return nil, errors.New("unable to parse dice quantity")
+	}
+
+	// fudge dice are a d3 read as -1, 0 or +1, and take no modifiers
+	if fudge {
+		term := &diceTerm{
+			notation: fmt.Sprintf("%ddF", quantity),
+			quantity: quantity,
+			sides:    3,
+			fudge:    true,
+		}
+		return &exprNode{kind: nodeDice, dice: term}, nil
+	}
+
+	tok := p.next()
+	if tok.kind != tokNum {
+		return nil, errors.New("unable to parse die type")
+	}
+
+	term := &diceTerm{
+		quantity: quantity,
+		sides:    tok.num,
+	}
+	notation := fmt.Sprintf("%dd%d", quantity, tok.num)
+
+	for {
+		tok := p.peek()
+		mod := strings.ToLower(tok.text)
+
+		switch {
+		case tok.kind == tokIdent && (mod == "k" || mod == "kh" || mod == "kl" || mod == "dh" || mod == "dl"):
+			p.next()
+			count := 1
+			if p.peek().kind == tokNum {
+				count = p.next().num
+			}
+			if count < 1 || count > quantity || (mod[0] == 'd' && count == quantity) {
+				return nil, fmt.Errorf("can't %s%d with %d dice", mod, count, quantity)
+			}
+
+			switch mod {
+			case "k", "kh":
+				term.keep, term.keepHigh = count, true
+			case "kl":
+				term.keep, term.keepHigh = count, false
+			case "dh":
+				term.keep, term.keepHigh = quantity-count, false
+			case "dl":
+				term.keep, term.keepHigh = quantity-count, true
+			}
+			notation += fmt.Sprintf("%s%d", mod, count)
+
+		case tok.kind == tokOp && (mod == "!" || mod == "!!"):
+			p.next()
+			cp, err := p.comparePoint(&comparePoint{op: "=", value: term.sides})
+			if err != nil {
+				return nil, err
+			}
+			term.explode, term.compound = cp, mod == "!!"
+			notation += mod + cp.String()
+
+		case tok.kind == tokIdent && (mod == "r" || mod == "ro"):
+			p.next()
+			cp, err := p.comparePoint(nil)
+			if err != nil {
+				return nil, err
+			}
+			term.reroll, term.rerollOnce = cp, mod == "ro"
+			notation += mod + cp.String()
+
+		case tok.kind == tokOp && (mod == "<" || mod == ">" || mod == "<=" || mod == ">=" || mod == "="):
+			cp, err := p.comparePoint(nil)
+			if err != nil {
+				return nil, err
+			}
+			term.success = cp
+			notation += cp.op + fmt.Sprintf("%d", cp.value)
+
+		case tok.kind == tokIdent && mod == "f":
+			p.next()
+			cp, err := p.comparePoint(nil)
+			if err != nil {
+				return nil, err
+			}
+			term.failure = cp
+			notation += mod + cp.String()
+
+		case tok.kind == tokIdent && (mod == "cs" || mod == "cf"):
+			p.next()
+			cp, err := p.comparePoint(nil)
+			if err != nil {
+				return nil, err
+			}
+			if mod == "cs" {
+				term.critHit = cp
+			} else {
+				term.critMiss = cp
+			}
+			notation += mod + cp.String()
+
+		case tok.kind == tokIdent && mod == "gl":
+			p.next()
+			term.glitch = true
+			notation += mod
+
+		case tok.kind == tokIdent && mod == "min":
+			p.next()
+			if p.peek().kind != tokNum {
+				return nil, errors.New("min needs a value. Eg: 2d6min2")
+			}
+			term.floor = p.next().num
+			notation += fmt.Sprintf("min%d", term.floor)
+
+		default:
+			term.notation = notation
+			// d20s crit on a natural 20 and fumble on a natural 1
+			// unless told otherwise
+			if term.sides == 20 && term.success == nil {
+				if term.critHit == nil {
+					term.critHit = &comparePoint{op: "=", value: 20}
+				}
+				if term.critMiss == nil {
+					term.critMiss = &comparePoint{op: "=", value: 1}
+				}
+			}
+			if err := term.validate(); err != nil {
+				return nil, err
+			}
+			return &exprNode{kind: nodeDice, dice: term}, nil
+		}
+	}
+}
+
+// comparePoint parses an optional comparison like <3, >=5 or a bare 1,
+// returning def when none is present
+func (p *diceParser) comparePoint(def *comparePoint) (*comparePoint, error) {
+	cp := &comparePoint{op: "="}
+
+	tok := p.peek()
+	if tok.kind == tokOp {
+		switch tok.text {
+		case "<", ">", "<=", ">=", "=":
+			cp.op = tok.text
+			p.next()
+		}
+	}
+
+	if p.peek().kind != tokNum {
+		if cp.op == "=" && def != nil {
+			return def, nil
+		}
+		return nil, errors.New("missing number in dice modifier")
+	}
+	cp.value = p.next().num
+
+	return cp, nil
+}
+
+func (c *comparePoint) match(v int) bool {
+	switch c.op {
+	case "<":
+		return v < c.value
+	case ">":
+		return v > c.value
+	case "<=":
+		return v <= c.value
+	case ">=":
+		return v >= c.value
+	}
+	return v == c.value
+}
+
+func (c *comparePoint) String() string {
+	if c.op == "=" {
+		return fmt.Sprintf("%d", c.value)
+	}
+	return fmt.Sprintf("%s%d", c.op, c.value)
+}
+
+// validate rejects modifiers that would never stop rolling or
+// that can't apply to the die
+func (t *diceTerm) validate() error {
+	if t.explode != nil && t.explode.match(1) && t.explode.match(t.sides) {
+		return fmt.Errorf("%s would explode forever", t.notation)
+	}
+	if t.reroll != nil && !t.rerollOnce && t.lowestFace() == 0 {
+		return fmt.Errorf("%s would reroll forever", t.notation)
+	}
+	if t.success == nil && (t.failure != nil || t.glitch) {
+		return fmt.Errorf("%s needs a success target. Eg: 8d10>=8f1", t.notation)
+	}
+	if t.success != nil && !t.canSucceed() {
+		return fmt.Errorf("%s can never succeed", t.notation)
+	}
+	if t.floor > t.sides {
+		return fmt.Errorf("min%d is higher than a d%d can roll", t.floor, t.sides)
+	}
+	return nil
+}
+
+// canSucceed reports whether any face the die can show meets its
+// success target. Compounding dice can add up past their sides.
+func (t *diceTerm) canSucceed() bool {
+	if t.explode != nil && t.compound {
+		return true
+	}
+	for face := 1; face <= t.sides; face++ {
+		if t.reroll != nil && !t.rerollOnce && t.reroll.match(face) {
+			continue
+		}
+		kept := face
+		if kept < t.floor {
+			kept = t.floor
+		}
+		if t.success.match(kept) {
+			return true
+		}
+	}
+	return false
+}
+
+// lowestFace is the lowest face a die can keep after
+// rerolls, or zero if every face would be rerolled
+func (t *diceTerm) lowestFace() int {
+	for face := 1; face <= t.sides; face++ {
+		if t.reroll == nil || t.rerollOnce || !t.reroll.match(face) {
+			return face
+		}
+	}
+	return 0
+}
+
+// diceTerms returns every dice group in the expression, left to right
+func (n *exprNode) diceTerms() []*diceTerm {
+	if n == nil {
+		return nil
+	}
+	if n.kind == nodeDice {
+		return []*diceTerm{n.dice}
+	}
+	return append(n.left.diceTerms(), n.right.diceTerms()...)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"testing"
+)
+
+var parseExprCases = []struct {
+	raw     string
+	want    string
+	wantErr bool
+}{
+	{
+		raw:  "1d20",
+		want: "1d20[]",
+	},
+	{
+		raw:  "2d6 + 1d4 + 3",
+		want: "2d6[] + 1d4[] + 3",
+	},
+	{
+		raw:  "(1d8+2)*2",
+		want: "(1d8[] + 2) * 2",
+	},
+	{
+		raw:  "1+2*3",
+		want: "1 + 2 * 3",
+	},
+	{
+		raw:  "-D6",
+		want: "-1d6[]",
+	},
+	{
+		raw:  "4d6KH3+2d20kl",
+		want: "4d6kh3[] + 2d20kl1[]",
+	},
+	{
+		raw:  "1d6!+2d6!!>=5",
+		want: "1d6!6[] + 2d6!!>=5[]",
+	},
+	{
+		raw:  "4d6r1kh3",
+		want: "4d6r1kh3[]",
+	},
+	{
+		raw:  "2d6ro<3min2",
+		want: "2d6ro<3min2[]",
+	},
+	{
+		raw:  "8d10>=8!f1",
+		want: "8d10>=8!10f1[]",
+	},
+	{
+		raw:  "12d6>4gl",
+		want: "12d6>4gl[]",
+	},
+	{
+		raw:  "4dF+dF",
+		want: "4dF[] + 1dF[]",
+	},
+	{
+		raw:  "1d20cs>=19cf<=2+5",
+		want: "1d20cs>=19cf<=2[] + 5",
+	},
+	{
+		raw:  "8d6=3",
+		want: "8d6=3[]",
+	},
+	{
+		raw:  "5d10=5+2d6!!>=7",
+		want: "5d10=5[] + 2d6!!>=7[]",
+	},
+	{
+		raw:     "1d6r6=6",
+		wantErr: true,
+	},
+	{
+		raw:     "1d6min3<=2",
+		wantErr: true,
+	},
+	{
+		raw:     "",
+		wantErr: true,
+	},
+	{
+		raw:     "3d6>=12",
+		wantErr: true,
+	},
+	{
+		raw:     "8d10f1",
+		wantErr: true,
+	},
+	{
+		raw:     "1d6!>=1",
+		wantErr: true,
+	},
+	{
+		raw:     "1d6r<7",
+		wantErr: true,
+	},
+	{
+		raw:     "1d6min7",
+		wantErr: true,
+	},
+	{
+		raw:     "1d6r",
+		wantErr: true,
+	},
+	{
+		raw:     "1d",
+		wantErr: true,
+	},
+	{
+		raw:     "2d6)",
+		wantErr: true,
+	},
+	{
+		raw:     "1d20/2",
+		wantErr: true,
+	},
+	{
+		raw:     "99999999d6",
+		wantErr: true,
+	},
+}
+
+func Test_parseExpr(t *testing.T) {
+	for _, tt := range parseExprCases {
+		t.Run(tt.raw, func(t *testing.T) {
+			expr, err := parseExpr(tt.raw)
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got nil: %s", tt.raw)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := expr.String(); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PbtaRoll is a Powered by the Apocalypse move: 2d6 plus a stat
+type PbtaRoll struct {
+	move string
+	stat int
+	dice []int
+}
+
+// stats run from -3 to +3 in most PbtA games, this leaves room for
+// ongoing bonuses on top
+const maxPbtaStat = 10
+
+// parsePbta reads the stat, and the move being made, for !pbta
+func parsePbta(args []string) (*PbtaRoll, error) {
+	p := &PbtaRoll{}
+	var move []string
+	found := false
+
+	for _, arg := range args {
+		if n, err := strconv.Atoi(arg); err == nil && !found {
+			if n < -maxPbtaStat || n > maxPbtaStat {
+				return nil, fmt.Errorf("stat must be -%d to +%d", maxPbtaStat, maxPbtaStat)
+			}
+			p.stat, found = n, true
+			continue
+		}
+		if arg != "#" {
+			move = append(move, strings.TrimPrefix(arg, "#"))
+		}
+	}
+
+	if !found {
+		return nil, errors.New("Missing stat. Eg: !pbta +2 go aggro")
+	}
+
+	p.move = strings.Join(move, " ")
+	return p, nil
+}
+
+func (p *PbtaRoll) roll(r *Roller) {
+	p.dice = []int{r.getRoll(6), r.getRoll(6)}
+}
+
+func (p *PbtaRoll) total() int {
+	return p.dice[0] + p.dice[1] + p.stat
+}
+
+// outcome is a strong hit on 10+, a weak hit on 7-9 and a miss on 6-
+func (p *PbtaRoll) outcome(format bool) string {
+	switch total := p.total(); {
+	case total >= 10:
+		return highlight("strong hit", ircGreen, format)
+	case total >= 7:
+		return highlight("weak hit", ircOrange, format)
+	}
+	return highlight("miss", ircRed, format)
+}
+
+// report describes the move for the channel
+func (p *PbtaRoll) report(nick string, format bool) string {
+	what := fmt.Sprintf("%+d", p.stat)
+	if p.move != "" {
+		what = fmt.Sprintf("%s (%+d)", p.move, p.stat)
+	}
+	return fmt.Sprintf("%s rolls %s: %d %d %+d = %d,  %s", nick, what, p.dice[0], p.dice[1], p.stat, p.total(), p.outcome(format))
+}
+
+// historyRow records the move for the roll log
+func (p *PbtaRoll) historyRow(nick, channel string) RollRow {
+	return RollRow{
+		nick:    strings.ToLower(nick),
+		channel: strings.ToLower(channel),
+		expr:    fmt.Sprintf("pbta %+d", p.stat),
+		label:   p.move,
+		dice:    fmt.Sprintf("d6:%d,%d", p.dice[0], p.dice[1]),
+		total:   p.total(),
+		time:    time.Now().UTC(),
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var pbtaCases = []struct {
+	args    string
+	faces   []int
+	want    string
+	wantErr bool
+}{
+	{args: "+2", faces: []int{4, 5}, want: "gbmor rolls +2: 4 5 +2 = 11,  strong hit"},
+	{args: "+2 go aggro", faces: []int{3, 2}, want: "gbmor rolls go aggro (+2): 3 2 +2 = 7,  weak hit"},
+	{args: "# act under fire -1", faces: []int{6, 3}, want: "gbmor rolls act under fire (-1): 6 3 -1 = 8,  weak hit"},
+	{args: "0 read a sitch", faces: []int{1, 5}, want: "gbmor rolls read a sitch (+0): 1 5 +0 = 6,  miss"},
+	{args: "go aggro", wantErr: true},
+	{args: "+11", wantErr: true},
+}
+
+func Test_PbtaRoll(t *testing.T) {
+	for _, tt := range pbtaCases {
+		t.Run(tt.args, func(t *testing.T) {
+			p, err := parsePbta(strings.Fields(tt.args))
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got %v", p)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			p.roll(fixedRoller(tt.faces...))
+			if got := p.report("gbmor", false); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	p, _ := parsePbta([]string{"+1"})
+	p.roll(fixedRoller(2, 3))
+	row := p.historyRow("gbmor", "#ttrpg")
+	if row.expr != "pbta +1" || row.dice != "d6:2,3" || row.total != 6 {
+		t.Errorf("Unexpected history row: %v", row)
+	}
+}
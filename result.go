@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RollResult holds everything about a rolled dice expression,
+// so it can be inspected as well as rendered for IRC
+type RollResult struct {
+	expr      *exprNode
+	terms     []TermResult
+	modifiers []int
+	total     int
+	min       int
+	max       int
+	unbounded bool
+	pool      bool
+	fudge     bool
+	nice      bool
+}
+
+// TermResult holds a single rolled dice group
+type TermResult struct {
+	notation  string
+	sides     int
+	dice      []dieRoll
+	total     int
+	min       int
+	max       int
+	successes int
+	failures  int
+	outcome   string
+	crits     []int
+	fumbles   []int
+}
+
+// result snapshots the term after it's been rolled
+func (t *diceTerm) result() TermResult {
+	min, max := t.bounds()
+
+	return TermResult{
+		notation:  t.notation,
+		sides:     t.sides,
+		dice:      append([]dieRoll(nil), t.rolls...),
+		total:     t.total,
+		min:       min,
+		max:       max,
+		successes: t.successes,
+		failures:  t.failures,
+		outcome:   t.outcome(),
+		crits:     append([]int(nil), t.crits...),
+		fumbles:   append([]int(nil), t.fumbles...),
+	}
+}
+
+// kept returns the dice that count toward the term's total
+func (t TermResult) kept() []dieRoll {
+	var out []dieRoll
+	for _, d := range t.dice {
+		if !d.dropped && !d.rerolled {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// IRC formatting codes
+const (
+	ircBold   = "\x02"
+	ircColor  = "\x03"
+	ircReset  = "\x0f"
+	ircGreen  = "03"
+	ircRed    = "04"
+	ircOrange = "07"
+)
+
+// critical reports whether any kept die landed in its crit range
+func (r *RollResult) critical() bool {
+	for _, term := range r.terms {
+		if len(term.crits) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fumbled reports whether any kept die landed in its fumble range
+func (r *RollResult) fumbled() bool {
+	for _, term := range r.terms {
+		if len(term.fumbles) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// diceString records every die's natural face for the roll history,
+// grouped by die type, with dropped and rerolled dice in parentheses.
+// Eg: "d20:(3),17 d6:4,2"
+func (r *RollResult) diceString() string {
+	terms := make([]string, 0, len(r.terms))
+	for _, term := range r.terms {
+		kind := fmt.Sprintf("d%d", term.sides)
+		if len(term.dice) > 0 && term.dice[0].fudge {
+			kind = "dF"
+		}
+
+		faces := make([]string, 0, len(term.dice))
+		for _, d := range term.dice {
+			if d.dropped || d.rerolled {
+				faces = append(faces, fmt.Sprintf("(%d)", d.raw))
+				continue
+			}
+			faces = append(faces, fmt.Sprintf("%d", d.raw))
+		}
+
+		terms = append(terms, kind+":"+strings.Join(faces, ","))
+	}
+	return strings.Join(terms, " ")
+}
+
+// String renders the result the way !roll reports it
+func (r *RollResult) String() string {
+	return r.render(false)
+}
+
+// render reports the result, optionally calling out
+// crits and fumbles in bold and color
+func (r *RollResult) render(format bool) string {
+	if r.nice {
+		return "n i c e"
+	}
+
+	label := "total"
+	if r.pool {
+		label = "successes"
+	}
+
+	var out strings.Builder
+
+	if len(r.terms) == 1 {
+		out.WriteString(joinRolls(r.terms[0].dice, "  "))
+	} else {
+		out.WriteString(r.expr.String())
+	}
+
+	// exploding dice have no real maximum, and fudge
+	// results are read off the ladder instead
+	switch {
+	case r.fudge && !r.pool:
+		out.WriteString(fmt.Sprintf(",  total: %+d %s", r.total, fateLadder(r.total)))
+	case r.unbounded:
+		out.WriteString(fmt.Sprintf(",  %s: %d", label, r.total))
+	default:
+		out.WriteString(fmt.Sprintf(",  %s: %d/%d", label, r.total, r.max))
+	}
+
+	for _, term := range r.terms {
+		if term.outcome != "" {
+			out.WriteString(fmt.Sprintf(",  %s", term.outcome))
+		}
+		for _, n := range term.crits {
+			out.WriteString(",  " + highlight(fmt.Sprintf("natural %d, critical hit!", n), ircGreen, format))
+		}
+		for _, n := range term.fumbles {
+			out.WriteString(",  " + highlight(fmt.Sprintf("natural %d, fumble!", n), ircRed, format))
+		}
+	}
+
+	return out.String()
+}
+
+func highlight(s, color string, format bool) string {
+	if !format {
+		return s
+	}
+	return ircBold + ircColor + color + s + ircReset
+}
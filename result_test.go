@@ -0,0 +1,199 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_roll(t *testing.T) {
+	res, err := newSeededRoller(1).roll("4d6kh3+1d4-2+3", defaultDiceLimits)
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+
+	if len(res.terms) != 2 {
+		t.Fatalf("Expected 2 terms, got %d", len(res.terms))
+	}
+	if !reflect.DeepEqual(res.modifiers, []int{-2, 3}) {
+		t.Errorf("Expected modifiers [-2 3], got %v", res.modifiers)
+	}
+	if res.min != 5 || res.max != 23 {
+		t.Errorf("Expected bounds 5/23, got %d/%d", res.min, res.max)
+	}
+
+	stats := res.terms[0]
+	if stats.notation != "4d6kh3" || stats.sides != 6 || len(stats.dice) != 4 {
+		t.Errorf("Unexpected term: %+v", stats)
+	}
+	if len(stats.kept()) != 3 {
+		t.Errorf("Expected 3 kept dice, got %v", stats.kept())
+	}
+	if stats.min != 3 || stats.max != 18 {
+		t.Errorf("Expected term bounds 3/18, got %d/%d", stats.min, stats.max)
+	}
+
+	total := 1
+	for _, term := range res.terms {
+		sum := 0
+		for _, d := range term.kept() {
+			sum += d.face
+		}
+		if sum != term.total {
+			t.Errorf("Term %s totals %d, but its kept dice sum to %d", term.notation, term.total, sum)
+		}
+		total += sum
+	}
+	if total != res.total {
+		t.Errorf("Expected total %d, got %d", total, res.total)
+	}
+}
+
+var signedModifiersCases = []struct {
+	raw string
+	min int
+	max int
+}{
+	{raw: "1d20-1", min: 0, max: 19},
+	{raw: "1d20+2+3", min: 6, max: 25},
+	{raw: "1d20+2-3", min: 0, max: 19},
+	{raw: "1d4-5", min: -4, max: -1},
+	{raw: "2d6-1+1d4-2", min: 0, max: 13},
+	{raw: "-2+1d8", min: -1, max: 6},
+}
+
+func Test_signedModifiers(t *testing.T) {
+	for _, tt := range signedModifiersCases {
+		t.Run(tt.raw, func(t *testing.T) {
+			res, err := testRoller.roll(tt.raw, defaultDiceLimits)
+			if err != nil {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if res.min != tt.min || res.max != tt.max {
+				t.Errorf("Expected bounds %d/%d, got %d/%d", tt.min, tt.max, res.min, res.max)
+			}
+			if res.total < res.min || res.total > res.max {
+				t.Errorf("Total %d outside of %d/%d", res.total, res.min, res.max)
+			}
+
+			dice := 0
+			for _, term := range res.terms {
+				dice += term.total
+			}
+			mods := 0
+			for _, mod := range res.modifiers {
+				mods += mod
+			}
+			if dice+mods != res.total {
+				t.Errorf("Expected dice %d and modifiers %v to total %d", dice, res.modifiers, res.total)
+			}
+		})
+	}
+}
+
+var modifiersCases = []struct {
+	raw  string
+	want []int
+}{
+	{raw: "1d20", want: nil},
+	{raw: "1d20+2+3", want: []int{2, 3}},
+	{raw: "1d20-1", want: []int{-1}},
+	{raw: "5-(1d6-2)", want: []int{5, 2}},
+	{raw: "(1d8+2)*2+1", want: []int{1}},
+}
+
+func Test_modifiers(t *testing.T) {
+	for _, tt := range modifiersCases {
+		t.Run(tt.raw, func(t *testing.T) {
+			expr, err := parseExpr(tt.raw)
+			if err != nil {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if got := expr.modifiers(1); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_RollResultString(t *testing.T) {
+	res := &RollResult{nice: true}
+	if res.String() != "n i c e" {
+		t.Errorf("69 didn't return n i c e")
+	}
+
+	res = &RollResult{
+		terms: []TermResult{{dice: []dieRoll{{raw: 3, face: 3}, {raw: 5, face: 5}}}},
+		total: 8,
+		max:   12,
+	}
+	if got := res.String(); got != "3  5,  total: 8/12" {
+		t.Errorf("Expected '3  5,  total: 8/12', got '%s'", got)
+	}
+}
+
+var markCritsCases = []struct {
+	raw     string
+	faces   []int
+	crits   []int
+	fumbles []int
+}{
+	{raw: "1d20", faces: []int{20}, crits: []int{20}},
+	{raw: "1d20", faces: []int{19}},
+	{raw: "1d20+5", faces: []int{1}, fumbles: []int{1}},
+	{raw: "1d20cs>=19", faces: []int{19}, crits: []int{19}},
+	{raw: "1d20cf<=2", faces: []int{2}, fumbles: []int{2}},
+	{raw: "2d20kh1", faces: []int{1, 20}, crits: []int{20}},
+	{raw: "2d20kl1", faces: []int{1, 20}, fumbles: []int{1}},
+	{raw: "1d6", faces: []int{6}},
+	{raw: "1d6cs6", faces: []int{6}, crits: []int{6}},
+}
+
+func Test_markCrits(t *testing.T) {
+	for _, tt := range markCritsCases {
+		t.Run(tt.raw, func(t *testing.T) {
+			expr, err := parseExpr(tt.raw)
+			if err != nil {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			term := expr.diceTerms()[0]
+			for _, face := range tt.faces {
+				term.rolls = append(term.rolls, dieRoll{raw: face, face: face})
+			}
+			term.applyKeep()
+			term.markCrits()
+
+			if !reflect.DeepEqual(term.crits, tt.crits) {
+				t.Errorf("Expected crits %v, got %v", tt.crits, term.crits)
+			}
+			if !reflect.DeepEqual(term.fumbles, tt.fumbles) {
+				t.Errorf("Expected fumbles %v, got %v", tt.fumbles, term.fumbles)
+			}
+		})
+	}
+}
+
+func Test_renderCrits(t *testing.T) {
+	res := &RollResult{
+		terms: []TermResult{{dice: []dieRoll{{raw: 20, face: 20, crit: true}}, crits: []int{20}}},
+		total: 25,
+		max:   25,
+	}
+
+	if got := res.render(false); got != "20,  total: 25/25,  natural 20, critical hit!" {
+		t.Errorf("Unexpected plain render: %s", got)
+	}
+	if got := res.render(true); got != "20,  total: 25/25,  \x02\x0303natural 20, critical hit!\x0f" {
+		t.Errorf("Unexpected formatted render: %q", got)
+	}
+	if !res.critical() || res.fumbled() {
+		t.Errorf("Expected a crit and no fumble")
+	}
+
+	crits := CritConf{hits: []string{"double damage"}, fumbles: []string{"drop your weapon"}}
+	if got := crits.tableResult(res, newSeededRoller(1)); got != "Critical hit: double damage" {
+		t.Errorf("Unexpected crit table result: %s", got)
+	}
+	if got := (CritConf{}).tableResult(res, newSeededRoller(1)); got != "" {
+		t.Errorf("Expected no table result without a table, got %s", got)
+	}
+}
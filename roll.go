@@ -0,0 +1,302 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rollRequest is a parsed !roll command
+type rollRequest struct {
+	expr  string
+	check *Check
+	label string
+	times int
+}
+
+// the most times one !roll will repeat its expression
+const maxRepeats = 20
+
+var repeatRegex = regexp.MustCompile(`(?i)^(\d+)x$`)
+
+var repeatCountRegex = regexp.MustCompile(`^-?\d+$`)
+
+var inlineRegex = regexp.MustCompile(`\[\[([^\[\]]+)\]\]`)
+
+// the most [[rolls]] one message will have evaluated
+const maxInlineRolls = 10
+
+// parseRollRequest splits a roll into its expression, any target
+// number, and a label. The label is either everything after a '#',
+// or whatever trails the longest run of words that parse as a roll.
+func parseRollRequest(s string) (*rollRequest, error) {
+	label := ""
+	if i := strings.Index(s, "#"); i >= 0 {
+		s, label = s[:i], strings.TrimSpace(s[i+1:])
+	}
+
+	words, times, err := parseRepeat(strings.Fields(s))
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, errors.New("missing dice expression")
+	}
+
+	// a target at the very end belongs to the roll even when a label
+	// sits between it and the dice, as in "1d20 to hit vs 15"
+	expr, target, err := parseCheck(strings.Join(words, " "))
+	if err != nil {
+		return nil, err
+	}
+	if target != nil {
+		words = strings.Fields(expr)
+		if len(words) == 0 {
+			return nil, errors.New("missing dice expression")
+		}
+	}
+
+	var firstErr error
+	for i := len(words); i > 0; i-- {
+		expr, check, err := parseCheck(strings.Join(words[:i], " "))
+		if err == nil && check != nil && target != nil {
+			return nil, errors.New("a roll can only have one target")
+		}
+		if err == nil {
+			_, err = parseExpr(expr)
+		}
+		if err == nil {
+			if check == nil {
+				check = target
+			}
+			rest := strings.Join(words[i:], " ")
+			if label != "" && rest != "" {
+				rest += " "
+			}
+			return &rollRequest{expr: expr, check: check, label: rest + label, times: times}, nil
+		}
+		firstErr = err
+	}
+
+	return nil, firstErr
+}
+
+// rollsDice reports whether a parsed expression has any dice in it
+func rollsDice(expr string) bool {
+	node, err := parseExpr(expr)
+	return err == nil && len(node.diceTerms()) > 0
+}
+
+// parseRepeat pulls a leading "6x" or a "repeat 6" out of the
+// words of a roll, returning the rest and how many times to roll.
+// "repeat" without a number after it is left for the label.
+func parseRepeat(words []string) ([]string, int, error) {
+	times := 0
+	var rest []string
+
+	for i := 0; i < len(words); i++ {
+		n := ""
+		switch {
+		case i == 0 && repeatRegex.MatchString(words[i]):
+			n = repeatRegex.FindStringSubmatch(words[i])[1]
+		case strings.EqualFold(words[i], "repeat") && i+1 < len(words) && times == 0 && repeatCountRegex.MatchString(words[i+1]):
+			i++
+			n = words[i]
+		default:
+			rest = append(rest, words[i])
+			continue
+		}
+
+		var err error
+		if times, err = strconv.Atoi(n); err != nil || times < 1 || times > maxRepeats {
+			return nil, 0, fmt.Errorf("can only repeat a roll 1 to %d times", maxRepeats)
+		}
+	}
+
+	if times == 0 {
+		times = 1
+	}
+
+	return rest, times, nil
+}
+
+// roll rolls the expression as many times as requested
+func (req *rollRequest) roll(r *Roller, limits DiceLimits) ([]*RollResult, error) {
+	results := make([]*RollResult, 0, req.times)
+
+	for i := 0; i < req.times; i++ {
+		res, err := r.roll(req.expr, limits)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// report describes the rolls for the channel, crediting the roller.
+// Repeated rolls are cut down to their totals to fit on one line.
+func (req *rollRequest) report(nick string, results []*RollResult, format bool) string {
+	what := req.label
+	if what == "" {
+		what = req.expr
+	}
+
+	if len(results) == 1 {
+		res := results[0]
+		out := fmt.Sprintf("%s rolls %s: %s", nick, what, res.render(format))
+		if req.check != nil {
+			out += ",  " + req.check.result(res)
+		}
+		return out
+	}
+
+	totals := make([]string, 0, len(results))
+	for _, res := range results {
+		totals = append(totals, req.summary(res, format))
+	}
+
+	return fmt.Sprintf("%s rolls %s %d times: %s", nick, what, len(results), strings.Join(totals, ",  "))
+}
+
+// summary is the total of one of a set of repeated rolls, noting
+// any crit, fumble or the result of a check
+func (req *rollRequest) summary(res *RollResult, format bool) string {
+	if res.nice {
+		return res.String()
+	}
+
+	out := fmt.Sprintf("%d", res.total)
+
+	var notes []string
+	if res.critical() {
+		notes = append(notes, highlight("crit", ircGreen, format))
+	}
+	if res.fumbled() {
+		notes = append(notes, highlight("fumble", ircRed, format))
+	}
+	if req.check != nil {
+		notes = append(notes, degreeNames[req.check.degree(res)])
+	}
+	if len(notes) > 0 {
+		out += fmt.Sprintf(" (%s)", strings.Join(notes, ", "))
+	}
+
+	return out
+}
+
+// historyRows records the results of a request for the roll history
+func (req *rollRequest) historyRows(nick, channel string, results []*RollResult, secret bool) []RollRow {
+	now := time.Now().UTC()
+	rows := make([]RollRow, 0, len(results))
+
+	for _, res := range results {
+		if res.nice {
+			continue
+		}
+		rows = append(rows, RollRow{
+			nick:    strings.ToLower(nick),
+			channel: strings.ToLower(channel),
+			expr:    req.expr,
+			label:   req.label,
+			dice:    res.diceString(),
+			total:   res.total,
+			secret:  secret,
+			time:    now,
+		})
+	}
+
+	return rows
+}
+
+// how many rolls !history shows by default, and at most
+const (
+	defaultHistory = 5
+	maxHistory     = 10
+)
+
+// parseHistoryArgs reads the optional nick and count given to !history
+func parseHistoryArgs(args []string) (string, int, error) {
+	nick, limit := "", defaultHistory
+
+	for _, arg := range args {
+		if arg == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(arg); err == nil {
+			if n < 1 || n > maxHistory {
+				return "", 0, fmt.Errorf("can only show 1 to %d rolls", maxHistory)
+			}
+			limit = n
+			continue
+		}
+		if nick != "" {
+			return "", 0, errors.New("Incorrect arguments. Eg: !history gbmor 5")
+		}
+		nick = strings.ToLower(arg)
+	}
+
+	return nick, limit, nil
+}
+
+// secretRecipients lists who sees a secret roll: the
+// roller, then each GM who isn't the roller
+func secretRecipients(nick string, gms []string) []string {
+	out := []string{nick}
+	for _, gm := range gms {
+		gm = strings.TrimSpace(gm)
+		dupe := gm == ""
+		for _, seen := range out {
+			if strings.EqualFold(gm, seen) {
+				dupe = true
+			}
+		}
+		if !dupe {
+			out = append(out, gm)
+		}
+	}
+	return out
+}
+
+// inlineRolls evaluates every [[roll]] in an ordinary message, returning
+// the message with the results in place, how many were rolled, and
+// the rolls for the history
+func (r *Roller) inlineRolls(nick, channel, msg string, limits DiceLimits, format bool) (string, int, []RollRow) {
+	count := 0
+	var rows []RollRow
+
+	out := inlineRegex.ReplaceAllStringFunc(msg, func(match string) string {
+		inner := strings.TrimSpace(match[2 : len(match)-2])
+		if count == maxInlineRolls {
+			return match
+		}
+
+		// brackets around anything that doesn't roll dice are
+		// someone else's markup, so they're left alone
+		req, err := parseRollRequest(inner)
+		if err != nil || !rollsDice(req.expr) {
+			return match
+		}
+		count++
+
+		results, err := req.roll(r, limits)
+		if err != nil {
+			return fmt.Sprintf("[%s: %s]", inner, err.Error())
+		}
+
+		rows = append(rows, req.historyRows(nick, channel, results, false)...)
+
+		totals := make([]string, 0, len(results))
+		for _, res := range results {
+			totals = append(totals, req.summary(res, format))
+		}
+
+		return fmt.Sprintf("[%s = %s]", inner, strings.Join(totals, ", "))
+	})
+
+	return out, count, rows
+}
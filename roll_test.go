@@ -0,0 +1,256 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var parseRollRequestCases = []struct {
+	raw     string
+	expr    string
+	label   string
+	dc      int
+	times   int
+	wantErr bool
+}{
+	{raw: "1d20+5", expr: "1d20+5"},
+	{raw: "1d20 + 5", expr: "1d20 + 5"},
+	{raw: "1d20+5 # attack on the goblin", expr: "1d20+5", label: "attack on the goblin"},
+	{raw: "1d20+5#sneaky", expr: "1d20+5", label: "sneaky"},
+	{raw: "2d6+3 fire damage", expr: "2d6+3", label: "fire damage"},
+	{raw: "1d20+5 vs 15 attack", expr: "1d20+5", label: "attack", dc: 15},
+	{raw: "1d20+5 dc15 # perception", expr: "1d20+5", label: "perception", dc: 15},
+	{raw: "1d20 to hit vs 15", expr: "1d20", label: "to hit", dc: 15},
+	{raw: "3x 1d20+5 to hit dc15 # goblins", expr: "1d20+5", label: "to hit goblins", dc: 15, times: 3},
+	{raw: "1d20 vs 12 to hit vs 15", wantErr: true},
+	{raw: "vs 15", wantErr: true},
+	{raw: "1d8 slashing # longsword", expr: "1d8", label: "slashing longsword"},
+	{raw: "6x 4d6kh3", expr: "4d6kh3", times: 6},
+	{raw: "4d6kh3 repeat 6", expr: "4d6kh3", times: 6},
+	{raw: "3X 1d20+5 vs 15 # attacks", expr: "1d20+5", label: "attacks", dc: 15, times: 3},
+	{raw: "21x 1d20", wantErr: true},
+	{raw: "1d20 repeat lots", expr: "1d20", label: "repeat lots"},
+	{raw: "1d20+5 repeat offender", expr: "1d20+5", label: "repeat offender"},
+	{raw: "1d20 repeat 30", wantErr: true},
+	{raw: "banana", wantErr: true},
+	{raw: "# just a label", wantErr: true},
+}
+
+func Test_parseRollRequest(t *testing.T) {
+	for _, tt := range parseRollRequestCases {
+		t.Run(tt.raw, func(t *testing.T) {
+			req, err := parseRollRequest(tt.raw)
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got nil: %s", tt.raw)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if req.expr != tt.expr || req.label != tt.label {
+				t.Errorf("Expected '%s' labelled '%s', got '%s' labelled '%s'", tt.expr, tt.label, req.expr, req.label)
+			}
+			if tt.times == 0 {
+				tt.times = 1
+			}
+			if req.times != tt.times {
+				t.Errorf("Expected %d repeats, got %d", tt.times, req.times)
+			}
+			if tt.dc != 0 && (req.check == nil || req.check.dc != tt.dc) {
+				t.Errorf("Expected dc %d, got %+v", tt.dc, req.check)
+			}
+		})
+	}
+}
+
+func Test_rollRequestReport(t *testing.T) {
+	res := &RollResult{
+		terms: []TermResult{{dice: []dieRoll{{raw: 14, face: 14}}}},
+		total: 19,
+		max:   25,
+	}
+
+	req := &rollRequest{expr: "1d20+5", label: "attack on the goblin", times: 1}
+	if got := req.report("gbmor", []*RollResult{res}, false); got != "gbmor rolls attack on the goblin: 14,  total: 19/25" {
+		t.Errorf("Unexpected report: %s", got)
+	}
+
+	req = &rollRequest{expr: "1d20+5", check: &Check{dc: 15}, times: 1}
+	if got := req.report("gbmor", []*RollResult{res}, false); got != "gbmor rolls 1d20+5: 14,  total: 19/25,  vs 15: success by 4" {
+		t.Errorf("Unexpected report: %s", got)
+	}
+}
+
+func Test_rollRequestRepeat(t *testing.T) {
+	req, err := parseRollRequest("6x 4d6kh3 # stats")
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+
+	results, err := req.roll(newSeededRoller(1), defaultDiceLimits)
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+	if len(results) != 6 {
+		t.Fatalf("Expected 6 results, got %d", len(results))
+	}
+
+	want := "gbmor rolls stats 6 times: 18,  7,  10,  14,  16,  14"
+	if got := req.report("gbmor", results, false); got != want {
+		t.Errorf("Expected '%s', got '%s'", want, got)
+	}
+
+	crit := &RollResult{terms: []TermResult{{crits: []int{20}}}, total: 27}
+	req = &rollRequest{expr: "1d20+7", check: &Check{dc: 15}, times: 2}
+	if got := req.summary(crit, false); got != "27 (crit, success)" {
+		t.Errorf("Unexpected summary: %s", got)
+	}
+}
+
+var inlineRollsCases = []struct {
+	msg   string
+	want  string
+	count int
+	rows  int
+}{
+	{
+		msg:   "just chatting",
+		want:  "just chatting",
+		count: 0,
+	},
+	{
+		msg:   "Thorin swings at the orc [[1d20+5]] for [[1d8+3]]",
+		want:  "Thorin swings at the orc [1d20+5 = 7] for [1d8+3 = 11]",
+		count: 2,
+		rows:  2,
+	},
+	{
+		msg:   "sneaking [[ 1d20+3 vs 15 ]]",
+		want:  "sneaking [1d20+3 vs 15 = 5 (failure)]",
+		count: 1,
+		rows:  1,
+	},
+	{
+		msg:   "[[banana]] split, see [[wiki page]] or [[2 things]]",
+		want:  "[[banana]] split, see [[wiki page]] or [[2 things]]",
+		count: 0,
+	},
+	{
+		msg:   "[[wiki page]] then [[1d2000]]",
+		want:  "[[wiki page]] then [1d2000: invalid die type: d2000]",
+		count: 1,
+	},
+	{
+		msg:   "not a roll [1d20]",
+		want:  "not a roll [1d20]",
+		count: 0,
+	},
+}
+
+func Test_inlineRolls(t *testing.T) {
+	for _, tt := range inlineRollsCases {
+		t.Run(tt.msg, func(t *testing.T) {
+			out, count, rows := newSeededRoller(1).inlineRolls("gbmor", "#ttrpg", tt.msg, defaultDiceLimits, false)
+			if out != tt.want || count != tt.count {
+				t.Errorf("Expected '%s' (%d), got '%s' (%d)", tt.want, tt.count, out, count)
+			}
+			if len(rows) != tt.rows {
+				t.Errorf("Expected %d history rows, got %d", tt.rows, len(rows))
+			}
+		})
+	}
+}
+
+var secretRecipientsCases = []struct {
+	name string
+	nick string
+	gms  []string
+	want []string
+}{
+	{
+		name: "no gms",
+		nick: "gbmor",
+		want: []string{"gbmor"},
+	},
+	{
+		name: "player rolls",
+		nick: "somenerd",
+		gms:  []string{"gbmor", "cogm"},
+		want: []string{"somenerd", "gbmor", "cogm"},
+	},
+	{
+		name: "gm rolls",
+		nick: "GBMOR",
+		gms:  []string{"gbmor", " cogm", ""},
+		want: []string{"GBMOR", "cogm"},
+	},
+}
+
+func Test_secretRecipients(t *testing.T) {
+	for _, tt := range secretRecipientsCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := secretRecipients(tt.nick, tt.gms); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+var parseHistoryArgsCases = []struct {
+	args    []string
+	nick    string
+	limit   int
+	wantErr bool
+}{
+	{args: nil, limit: defaultHistory},
+	{args: []string{"GBMOR"}, nick: "gbmor", limit: defaultHistory},
+	{args: []string{"gbmor", "3"}, nick: "gbmor", limit: 3},
+	{args: []string{"10"}, limit: 10},
+	{args: []string{"11"}, wantErr: true},
+	{args: []string{"gbmor", "somenerd"}, wantErr: true},
+}
+
+func Test_parseHistoryArgs(t *testing.T) {
+	for _, tt := range parseHistoryArgsCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			nick, limit, err := parseHistoryArgs(tt.args)
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got nil: %v", tt.args)
+			}
+			if nick != tt.nick || limit != tt.limit {
+				t.Errorf("Expected %s/%d, got %s/%d", tt.nick, tt.limit, nick, limit)
+			}
+		})
+	}
+}
+
+func Test_historyRows(t *testing.T) {
+	req, err := parseRollRequest("2x 4d6kh3 # stats")
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+	results, err := req.roll(newSeededRoller(1), defaultDiceLimits)
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+
+	rows := req.historyRows("GBMOR", "#TTRPG", results, true)
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+
+	row := rows[0]
+	if row.nick != "gbmor" || row.channel != "#ttrpg" || row.expr != "4d6kh3" || row.label != "stats" || !row.secret {
+		t.Errorf("Unexpected row: %+v", row)
+	}
+	if row.dice != "d6:6,(4),6,6" || row.total != 18 {
+		t.Errorf("Expected d6:6,(4),6,6 = 18, got %s = %d", row.dice, row.total)
+	}
+}
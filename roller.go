@@ -0,0 +1,57 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"log"
+	"math/big"
+	"math/rand"
+	"time"
+)
+
+// RandSource is where a Roller gets its randomness
+type RandSource interface {
+	// Intn returns a number in [0,n)
+	Intn(n int) int
+}
+
+// Roller throws dice using its RandSource
+type Roller struct {
+	src RandSource
+}
+
+// cryptoSource draws from crypto/rand so rolls can't be
+// predicted from earlier ones, or repeated across restarts
+type cryptoSource struct {
+	fallback *rand.Rand
+}
+
+func newRoller(src RandSource) *Roller {
+	return &Roller{src: src}
+}
+
+// newCryptoRoller is the Roller the bot uses to serve !roll
+func newCryptoRoller() *Roller {
+	return newRoller(&cryptoSource{
+		fallback: rand.New(rand.NewSource(time.Now().UnixNano())),
+	})
+}
+
+// newSeededRoller produces the same rolls for the same seed
+func newSeededRoller(seed int64) *Roller {
+	return newRoller(rand.New(rand.NewSource(seed)))
+}
+
+func (c *cryptoSource) Intn(n int) int {
+	out, err := crand.Int(crand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		log.Printf("Couldn't read from crypto/rand, falling back to math/rand: %s", err.Error())
+		return c.fallback.Intn(n)
+	}
+	return int(out.Int64())
+}
+
+// [0,n] exclusive on the upper bound
+func (r *Roller) getRoll(ceiling int) int {
+	out := r.src.Intn(ceiling)
+	return out + 1
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// Seal commits a channel's rolls to a secret seed. Its hash is published
+// when the seal is made and the seed when it's revealed, so anyone can
+// check the rolls made in between weren't tampered with.
+type Seal struct {
+	id       int
+	channel  string
+	seed     string
+	hash     string
+	revealed bool
+	time     time.Time
+}
+
+// seedSource draws the dice for one roll command made under a seal.
+// The nth number comes from the first 8 bytes, big-endian, of
+// sha256("seed:seq:n"), discarding any that would bias the result.
+type seedSource struct {
+	seed    string
+	seq     int
+	counter uint64
+}
+
+// logged rolls were within limits when they were made,
+// so replaying them shouldn't be refused
+var verifyLimits = DiceLimits{
+	maxSides: math.MaxInt32,
+	maxDice:  math.MaxInt32,
+}
+
+// newSeal makes a seal with a fresh random seed
+func newSeal(channel string) (*Seal, error) {
+	buf := make([]byte, 32)
+	if _, err := crand.Read(buf); err != nil {
+		return nil, fmt.Errorf("Couldn't generate seed: %w", err)
+	}
+
+	seed := hex.EncodeToString(buf)
+	return &Seal{
+		channel: strings.ToLower(channel),
+		seed:    seed,
+		hash:    sealHash(seed),
+		time:    time.Now().UTC(),
+	}, nil
+}
+
+// sealHash is the commitment published for a seed, the same
+// as `printf %s $SEED | sha256sum` gives
+func sealHash(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// roller throws the dice for the seq'th roll command under the seal
+func (s *Seal) roller(seq int) *Roller {
+	return newRoller(&seedSource{seed: s.seed, seq: seq})
+}
+
+func (s *seedSource) next() uint64 {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d", s.seed, s.seq, s.counter)))
+	s.counter++
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func (s *seedSource) Intn(n int) int {
+	limit := math.MaxUint64 - math.MaxUint64%uint64(n)
+	for {
+		if v := s.next(); v < limit {
+			return int(v % uint64(n))
+		}
+	}
+}
+
+// sealedRoller picks the roller for a roll command in channel: the open
+// seal's if it has one, and if not the fallback
+func (db *DB) sealedRoller(channel string, fallback *Roller) (*Roller, *Seal, int) {
+	seal, err := db.getSeal(channel, 0, false)
+	if err != nil {
+		log.Printf("When retrieving seal for %s: %s", channel, err.Error())
+		return fallback, nil, 0
+	}
+	if seal == nil {
+		return fallback, nil, 0
+	}
+
+	seq, err := db.nextSeq(seal.id)
+	if err != nil {
+		log.Printf("When numbering roll under seal %d: %s", seal.id, err.Error())
+		return fallback, nil, 0
+	}
+
+	return seal.roller(seq), seal, seq
+}
+
+// sealRows records the seal and roll command the rows were rolled under
+func sealRows(rows []RollRow, seal *Seal, seq int) {
+	if seal == nil {
+		return
+	}
+	for i := range rows {
+		rows[i].session = seal.id
+		rows[i].seq = seq
+	}
+}
+
+// verify checks the seed matches the published hash, then rolls every
+// logged roll again from the seed. It returns how many rolls it checked
+// and describes any that came out differently.
+func (s *Seal) verify(rows []RollRow) (int, []string) {
+	var bad []string
+	if sealHash(s.seed) != s.hash {
+		bad = append(bad, "the seed doesn't match its commitment")
+	}
+
+	var r *Roller
+	seq := -1
+	for i, row := range rows {
+		if row.seq != seq {
+			seq = row.seq
+			r = s.roller(seq)
+		}
+
+		dice, total, err := replay(r, row.expr)
+		if err != nil {
+			bad = append(bad, fmt.Sprintf("#%d %s's %s: %s", i+1, row.nick, row.expr, err.Error()))
+			continue
+		}
+		if dice != row.dice || total != row.total {
+			bad = append(bad, fmt.Sprintf("#%d %s's %s was %s = %d, should be %s = %d",
+				i+1, row.nick, row.expr, row.dice, row.total, dice, total))
+		}
+	}
+
+	return len(rows), bad
+}
+
+// replay rolls a logged roll again, returning its dice and total. Game
+// system rolls are logged as their command and its arguments, like
+// "coc 65 b1", and anything else is a dice expression.
+func replay(r *Roller, expr string) (string, int, error) {
+	if words := strings.Fields(expr); len(words) > 0 {
+		sr, err := parseSystemRoll("!"+words[0], words[1:])
+		if err != nil {
+			return "", 0, err
+		}
+		if sr != nil {
+			sr.roll(r)
+			row := sr.historyRow("", "")
+			return row.dice, row.total, nil
+		}
+	}
+
+	res, err := r.roll(expr, verifyLimits)
+	if err != nil {
+		return "", 0, err
+	}
+	return res.diceString(), res.total, nil
+}
+
+// canSeal reports whether nick may make or reveal a seal in a channel:
+// its GMs if it has any, otherwise anyone
+func canSeal(nick string, gms []string) bool {
+	if len(gms) == 0 {
+		return true
+	}
+	for _, gm := range gms {
+		if strings.EqualFold(gm, nick) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_sealHash(t *testing.T) {
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := sealHash("abc"); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func Test_seedSource(t *testing.T) {
+	seal := &Seal{seed: "abc", hash: sealHash("abc")}
+
+	a, b := seal.roller(1), seal.roller(1)
+	other := seal.roller(2)
+	same, differ := true, false
+	for i := 0; i < 50; i++ {
+		x, y, z := a.getRoll(20), b.getRoll(20), other.getRoll(20)
+		if x < 1 || x > 20 {
+			t.Fatalf("Roll out of range: %d", x)
+		}
+		same = same && x == y
+		differ = differ || x != z
+	}
+
+	if !same {
+		t.Errorf("The same seed and sequence should roll the same")
+	}
+	if !differ {
+		t.Errorf("Different sequences should roll differently")
+	}
+}
+
+func Test_Seal_verify(t *testing.T) {
+	seal, err := newSeal("#dnd")
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+	seal.id = 1
+
+	var rows []RollRow
+	for seq, raw := range []string{"6x 4d6kh3", "1d20+5 # attack", "2d6!"} {
+		req, err := parseRollRequest(raw)
+		if err != nil {
+			t.Fatalf("Got unexpected error: %s", err.Error())
+		}
+		results, err := req.roll(seal.roller(seq+1), defaultDiceLimits)
+		if err != nil {
+			t.Fatalf("Got unexpected error: %s", err.Error())
+		}
+		made := req.historyRows("gbmor", "#dnd", results, false)
+		sealRows(made, seal, seq+1)
+		rows = append(rows, made...)
+	}
+
+	checked, bad := seal.verify(rows)
+	if checked != 8 || len(bad) != 0 {
+		t.Errorf("Expected 8 good rolls, got %d with problems: %v", checked, bad)
+	}
+
+	rows[3].total++
+	if _, bad = seal.verify(rows); len(bad) != 1 || !strings.HasPrefix(bad[0], "#4 gbmor's 4d6kh3") {
+		t.Errorf("Expected the tampered roll to be found, got %v", bad)
+	}
+
+	seal.seed = "abc"
+	if _, bad = seal.verify(nil); len(bad) != 1 {
+		t.Errorf("Expected the seed not to match its commitment, got %v", bad)
+	}
+}
+
+func Test_Seal_verifyInlineRolls(t *testing.T) {
+	seal, err := newSeal("#dnd")
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+	seal.id = 1
+
+	// the refused roll mustn't use up dice meant for the one after it
+	out, count, rows := seal.roller(1).inlineRolls("bob", "#dnd", "a [[1d6*1000000*1000000]] b [[1d20]]", defaultDiceLimits, false)
+	if count != 2 || len(rows) != 1 || !strings.Contains(out, "number too large") {
+		t.Fatalf("Unexpected inline rolls: %d rolls, %v: %s", count, rows, out)
+	}
+	sealRows(rows, seal, 1)
+
+	if checked, bad := seal.verify(rows); checked != 1 || len(bad) != 0 {
+		t.Errorf("Expected 1 good roll, got %d with problems: %v", checked, bad)
+	}
+}
+
+func Test_canSeal(t *testing.T) {
+	if !canSeal("anyone", nil) {
+		t.Errorf("Anyone should be able to seal a channel without GMs")
+	}
+	if !canSeal("GBMor", []string{"gbmor"}) {
+		t.Errorf("A GM should be able to seal")
+	}
+	if canSeal("player", []string{"gbmor"}) {
+		t.Errorf("A player shouldn't be able to seal a channel with GMs")
+	}
+}
+
+func Test_Seal_verifySystemRolls(t *testing.T) {
+	seal, err := newSeal("#dnd")
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+	seal.id = 1
+
+	var rows []RollRow
+	for seq, raw := range []string{
+		"!coc Spot Hidden 65 b1 hard",
+		"!pbta +2 go aggro",
+		"!blades 0",
+		"!ironsworn action +2 -3",
+		"!ironsworn progress 6",
+		"!sw d8+1 tn 6",
+		"!nd 2a 1p 2d 1c 1f",
+		"!roll 2d6+3",
+	} {
+		words := strings.Fields(raw)
+		sr, err := parseSystemRoll(words[0], words[1:])
+		if err != nil {
+			t.Fatalf("Got unexpected error: %s", err.Error())
+		}
+
+		var made []RollRow
+		if sr == nil {
+			req, _ := parseRollRequest(strings.Join(words[1:], " "))
+			results, _ := req.roll(seal.roller(seq+1), defaultDiceLimits)
+			made = req.historyRows("gbmor", "#dnd", results, false)
+		} else {
+			sr.roll(seal.roller(seq + 1))
+			made = []RollRow{sr.historyRow("gbmor", "#dnd")}
+		}
+		sealRows(made, seal, seq+1)
+		rows = append(rows, made...)
+	}
+
+	checked, bad := seal.verify(rows)
+	if checked != len(rows) || len(bad) != 0 {
+		t.Errorf("Expected %d good rolls, got %d with problems: %v", len(rows), checked, bad)
+	}
+
+	rows[0].total++
+	if _, bad = seal.verify(rows); len(bad) != 1 || !strings.HasPrefix(bad[0], "#1 gbmor's coc 65") {
+		t.Errorf("Expected the tampered roll to be found, got %v", bad)
+	}
+}
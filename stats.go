@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DieStats tallies the natural faces rolled on one kind of die
+type DieStats struct {
+	sides  int
+	counts []int
+	count  int
+	sum    int
+}
+
+// LuckRow is a single player's place on a luck leaderboard
+type LuckRow struct {
+	nick string
+	luck float64
+	dice int
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// tallyDice counts up the faces in dice strings from the roll
+// history, by die type. Fudge dice aren't counted.
+func tallyDice(rolls []string) map[int]*DieStats {
+	stats := make(map[int]*DieStats)
+
+	for _, roll := range rolls {
+		for _, term := range strings.Fields(roll) {
+			split := strings.SplitN(term, ":", 2)
+			if len(split) != 2 || !strings.HasPrefix(split[0], "d") {
+				continue
+			}
+			sides, err := strconv.Atoi(split[0][1:])
+			if err != nil || sides < 1 {
+				continue
+			}
+
+			s, ok := stats[sides]
+			if !ok {
+				s = &DieStats{sides: sides, counts: make([]int, sides+1)}
+				stats[sides] = s
+			}
+
+			for _, face := range strings.Split(split[1], ",") {
+				n, err := strconv.Atoi(strings.Trim(face, "()"))
+				if err != nil || n < 1 || n > sides {
+					continue
+				}
+				s.counts[n]++
+				s.count++
+				s.sum += n
+			}
+		}
+	}
+
+	return stats
+}
+
+func (s *DieStats) mean() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return float64(s.sum) / float64(s.count)
+}
+
+func (s *DieStats) expected() float64 {
+	return float64(s.sides+1) / 2
+}
+
+// histogram draws the share of each face as a sparkline
+func (s *DieStats) histogram() string {
+	most := 0
+	for _, n := range s.counts[1:] {
+		if n > most {
+			most = n
+		}
+	}
+
+	var out strings.Builder
+	for _, n := range s.counts[1:] {
+		if most == 0 {
+			out.WriteRune(sparks[0])
+			continue
+		}
+		out.WriteRune(sparks[n*(len(sparks)-1)/most])
+	}
+	return out.String()
+}
+
+// summary is the one-line report for a kind of die
+func (s *DieStats) summary() string {
+	out := fmt.Sprintf("d%d: %d rolled, mean %.2f (expected %.2f)", s.sides, s.count, s.mean(), s.expected())
+	if s.sides == 20 {
+		out += fmt.Sprintf(", %d nat 20s, %d nat 1s", s.counts[20], s.counts[1])
+	}
+	return out
+}
+
+// luck is how far above or below average the faces
+// rolled have been, from -50% to +50%
+func luck(stats map[int]*DieStats) (float64, int) {
+	total, dice := 0.0, 0
+	for _, s := range stats {
+		if s.sides < 2 {
+			continue
+		}
+		for face, n := range s.counts {
+			if face == 0 {
+				continue
+			}
+			total += float64(n) * float64(face-1) / float64(s.sides-1)
+			dice += n
+		}
+	}
+
+	if dice == 0 {
+		return 0, 0
+	}
+	return (total/float64(dice) - 0.5) * 100, dice
+}
+
+// rollStats reports on the dice in a set of rolls, either every kind
+// of die or only the given one. d20s get a histogram of their faces.
+func rollStats(who string, rolls []string, die int) ([]string, error) {
+	stats := tallyDice(rolls)
+
+	var kinds []int
+	for sides := range stats {
+		if die == 0 || sides == die {
+			kinds = append(kinds, sides)
+		}
+	}
+	if len(kinds) == 0 {
+		return nil, fmt.Errorf("No rolls to show for %s", who)
+	}
+	sort.Ints(kinds)
+
+	var summaries []string
+	for _, sides := range kinds {
+		summaries = append(summaries, stats[sides].summary())
+	}
+	out := []string{fmt.Sprintf("%s: %s", who, strings.Join(summaries, "; "))}
+
+	hist := 20
+	if die != 0 && die <= 20 {
+		hist = die
+	}
+	if s, ok := stats[hist]; ok {
+		out = append(out, fmt.Sprintf("d%d faces 1-%d: %s", hist, hist, s.histogram()))
+	}
+
+	if die == 0 {
+		l, dice := luck(stats)
+		out = append(out, fmt.Sprintf("luck: %+.1f%% over %d dice", l, dice))
+	}
+
+	return out, nil
+}
+
+// leaderboard ranks players by luck, luckiest first
+func leaderboard(rolls map[string][]string) []LuckRow {
+	var out []LuckRow
+	for nick, dice := range rolls {
+		l, n := luck(tallyDice(dice))
+		if n == 0 {
+			continue
+		}
+		out = append(out, LuckRow{nick: nick, luck: l, dice: n})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].luck == out[j].luck {
+			return out[i].nick < out[j].nick
+		}
+		return out[i].luck > out[j].luck
+	})
+
+	return out
+}
+
+// parseRollStatsArgs reads the optional nick and die type given to
+// !rollstats. Eg: !rollstats gbmor d20
+func parseRollStatsArgs(args []string) (string, int, error) {
+	nick, die := "", 0
+
+	for _, arg := range args {
+		if arg == "" {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(arg), "d") {
+			if n, err := strconv.Atoi(arg[1:]); err == nil && n > 0 {
+				die = n
+				continue
+			}
+		}
+		if nick != "" {
+			return "", 0, errors.New("Incorrect arguments. Eg: !rollstats gbmor d20")
+		}
+		nick = strings.ToLower(arg)
+	}
+
+	return nick, die, nil
+}
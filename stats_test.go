@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testRollDice = []string{
+	"d20:20",
+	"d20:(1),15 d6:3,4",
+	"d20:1",
+	"dF:1,0,-1",
+	"d6:6,(2),6",
+}
+
+func Test_tallyDice(t *testing.T) {
+	stats := tallyDice(testRollDice)
+	if len(stats) != 2 {
+		t.Fatalf("Expected d20 and d6 stats, got %v", stats)
+	}
+
+	d20 := stats[20]
+	if d20.count != 4 || d20.sum != 37 || d20.counts[1] != 2 || d20.counts[20] != 1 {
+		t.Errorf("Unexpected d20 stats: %+v", d20)
+	}
+	if d20.mean() != 9.25 || d20.expected() != 10.5 {
+		t.Errorf("Expected mean 9.25 vs 10.5, got %v vs %v", d20.mean(), d20.expected())
+	}
+
+	d6 := stats[6]
+	if d6.count != 5 || d6.sum != 21 {
+		t.Errorf("Unexpected d6 stats: %+v", d6)
+	}
+	if got := d6.histogram(); got != "▁▄▄▄▁█" {
+		t.Errorf("Unexpected histogram: %s", got)
+	}
+}
+
+func Test_luck(t *testing.T) {
+	l, dice := luck(tallyDice([]string{"d20:20", "d6:1"}))
+	if l != 0 || dice != 2 {
+		t.Errorf("Expected a max and a min to average out, got %v over %d", l, dice)
+	}
+
+	l, _ = luck(tallyDice([]string{"d20:20,20"}))
+	if l != 50 {
+		t.Errorf("Expected +50%% luck, got %v", l)
+	}
+
+	if l, dice := luck(nil); l != 0 || dice != 0 {
+		t.Errorf("Expected no luck without dice, got %v over %d", l, dice)
+	}
+}
+
+func Test_rollStats(t *testing.T) {
+	out, err := rollStats("gbmor", testRollDice, 0)
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+	if len(out) != 3 {
+		t.Fatalf("Expected 3 lines, got %v", out)
+	}
+	want := "gbmor: d6: 5 rolled, mean 4.20 (expected 3.50); d20: 4 rolled, mean 9.25 (expected 10.50), 1 nat 20s, 2 nat 1s"
+	if out[0] != want {
+		t.Errorf("Expected '%s', got '%s'", want, out[0])
+	}
+	if !strings.HasPrefix(out[1], "d20 faces 1-20: ") || !strings.HasPrefix(out[2], "luck: ") {
+		t.Errorf("Unexpected histogram or luck: %v", out[1:])
+	}
+
+	out, err = rollStats("gbmor", testRollDice, 6)
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+	if len(out) != 2 || out[1] != "d6 faces 1-6: ▁▄▄▄▁█" {
+		t.Errorf("Unexpected d6 stats: %v", out)
+	}
+
+	if _, err := rollStats("gbmor", testRollDice, 8); err == nil {
+		t.Error("Expected error for a die never rolled")
+	}
+}
+
+func Test_leaderboard(t *testing.T) {
+	rolls := map[string][]string{
+		"unlucky":  {"d20:1,2,3"},
+		"lucky":    {"d20:20,19"},
+		"nodice":   nil,
+		"middling": {"d6:3,4"},
+	}
+
+	var got []string
+	for _, row := range leaderboard(rolls) {
+		got = append(got, row.nick)
+	}
+	if want := []string{"lucky", "middling", "unlucky"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+var parseRollStatsArgsCases = []struct {
+	args    []string
+	nick    string
+	die     int
+	wantErr bool
+}{
+	{args: nil},
+	{args: []string{"GBMOR"}, nick: "gbmor"},
+	{args: []string{"d20"}, die: 20},
+	{args: []string{"gbmor", "D6"}, nick: "gbmor", die: 6},
+	{args: []string{"dwarfbro"}, nick: "dwarfbro"},
+	{args: []string{"gbmor", "somenerd"}, wantErr: true},
+}
+
+func Test_parseRollStatsArgs(t *testing.T) {
+	for _, tt := range parseRollStatsArgsCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			nick, die, err := parseRollStatsArgs(tt.args)
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got nil: %v", tt.args)
+			}
+			if nick != tt.nick || die != tt.die {
+				t.Errorf("Expected %s/d%d, got %s/d%d", tt.nick, tt.die, nick, die)
+			}
+		})
+	}
+}
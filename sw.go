@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SavageRoll is a Savage Worlds trait roll: an acing trait die and,
+// for Wild Cards, an acing d6 wild die, keeping the higher
+type SavageRoll struct {
+	label string
+	sides int
+	mod   int
+	tn    int
+	extra bool
+	trait []int
+	wild  []int
+}
+
+const defaultSavageTN = 4
+
+var savageTraitRegex = regexp.MustCompile(`(?i)^d(\d+)([+-]\d+)?$`)
+
+// parseSavage reads the trait die for !sw, along with any modifier,
+// target number ("tn 6" or "vs 6") and "extra" to roll without a
+// wild die. Anything else is taken as a label.
+func parseSavage(args []string) (*SavageRoll, error) {
+	s := &SavageRoll{tn: defaultSavageTN}
+	var label []string
+
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToLower(args[i])
+
+		if match := savageTraitRegex.FindStringSubmatch(arg); match != nil && s.sides == 0 {
+			s.sides, _ = strconv.Atoi(match[1])
+			if match[2] != "" {
+				s.mod, _ = strconv.Atoi(match[2])
+			}
+			continue
+		}
+		if n, err := strconv.Atoi(arg); err == nil && strings.ContainsAny(arg, "+-") {
+			s.mod += n
+			continue
+		}
+
+		switch {
+		case arg == "tn" || arg == "vs":
+			if i+1 == len(args) {
+				return nil, errors.New("Missing target number. Eg: !sw d8 tn 6")
+			}
+			i++
+			arg = args[i]
+			fallthrough
+		case strings.HasPrefix(arg, "tn"):
+			tn, err := strconv.Atoi(strings.TrimPrefix(arg, "tn"))
+			if err != nil || tn < 1 || tn > maxLiteral {
+				return nil, fmt.Errorf("unable to parse target number: %s", arg)
+			}
+			s.tn = tn
+		case arg == "extra" || arg == "nowild":
+			s.extra = true
+		case arg != "#":
+			label = append(label, strings.TrimPrefix(args[i], "#"))
+		}
+	}
+
+	switch s.sides {
+	case 0:
+		return nil, errors.New("Missing trait die. Eg: !sw d8")
+	case 4, 6, 8, 10, 12:
+	default:
+		return nil, errors.New("trait die must be d4, d6, d8, d10 or d12")
+	}
+	if s.mod < -maxLiteral || s.mod > maxLiteral {
+		return nil, errors.New("number too large")
+	}
+
+	s.label = strings.Join(label, " ")
+	return s, nil
+}
+
+// ace rolls a die, rolling again and adding on every maximum
+func ace(r *Roller, sides int) []int {
+	out := []int{r.getRoll(sides)}
+	for n := 0; n < maxExplosions && out[len(out)-1] == sides; n++ {
+		out = append(out, r.getRoll(sides))
+	}
+	return out
+}
+
+func (s *SavageRoll) roll(r *Roller) {
+	s.trait = ace(r, s.sides)
+	s.wild = nil
+	if !s.extra {
+		s.wild = ace(r, 6)
+	}
+}
+
+func sum(dice []int) int {
+	out := 0
+	for _, d := range dice {
+		out += d
+	}
+	return out
+}
+
+// total is the higher of the trait and wild dice, with the modifier
+func (s *SavageRoll) total() int {
+	total := sum(s.trait)
+	if w := sum(s.wild); !s.extra && w > total {
+		total = w
+	}
+	return total + s.mod
+}
+
+// wildKept reports whether the wild die beat the trait die
+func (s *SavageRoll) wildKept() bool {
+	return !s.extra && sum(s.wild) > sum(s.trait)
+}
+
+// critFailed reports a critical failure: a Wild Card rolling 1 on
+// both the trait and wild dice
+func (s *SavageRoll) critFailed() bool {
+	return !s.extra && s.trait[0] == 1 && s.wild[0] == 1
+}
+
+// outcome reports success, with a raise for every 4 over the target
+func (s *SavageRoll) outcome(format bool) string {
+	margin := s.total() - s.tn
+	switch raises := margin / 4; {
+	case s.critFailed():
+		return highlight("critical failure!", ircRed, format)
+	case margin < 0:
+		return highlight("failure", ircRed, format)
+	case raises == 0:
+		return highlight("success", ircGreen, format)
+	case raises == 1:
+		return highlight("success with a raise", ircGreen, format)
+	default:
+		return highlight(fmt.Sprintf("success with %d raises", raises), ircGreen, format)
+	}
+}
+
+// acedString shows a die's rolls, marking each ace with !
+func acedString(dice []int) string {
+	out := make([]string, 0, len(dice))
+	for i, d := range dice {
+		if i < len(dice)-1 {
+			out = append(out, fmt.Sprintf("%d!", d))
+			continue
+		}
+		out = append(out, strconv.Itoa(d))
+	}
+	return strings.Join(out, " ")
+}
+
+// report describes the roll for the channel, with the die not
+// kept in parentheses
+func (s *SavageRoll) report(nick string, format bool) string {
+	what := fmt.Sprintf("d%d", s.sides)
+	if s.mod != 0 {
+		what += fmt.Sprintf("%+d", s.mod)
+	}
+	if s.label != "" {
+		what = fmt.Sprintf("%s (%s)", s.label, what)
+	}
+
+	trait := fmt.Sprintf("trait %s", acedString(s.trait))
+	if s.wildKept() {
+		trait = fmt.Sprintf("trait (%s)", acedString(s.trait))
+	}
+
+	rolled := trait
+	if !s.extra {
+		wild := fmt.Sprintf("(%s)", acedString(s.wild))
+		if s.wildKept() {
+			wild = acedString(s.wild)
+		}
+		rolled += ", wild " + wild
+	}
+	if s.mod != 0 {
+		rolled += fmt.Sprintf(", %+d", s.mod)
+	}
+
+	return fmt.Sprintf("%s rolls %s: %s = %d vs %d,  %s", nick, what, rolled, s.total(), s.tn, s.outcome(format))
+}
+
+// historyRow records the roll for the roll log, with
+// the die not kept in parentheses
+func (s *SavageRoll) historyRow(nick, channel string) RollRow {
+	expr := fmt.Sprintf("sw d%d", s.sides)
+	if s.mod != 0 {
+		expr += fmt.Sprintf("%+d", s.mod)
+	}
+	if s.tn != defaultSavageTN {
+		expr += fmt.Sprintf(" tn %d", s.tn)
+	}
+	if s.extra {
+		expr += " extra"
+	}
+
+	dice := fmt.Sprintf("d%d:%s", s.sides, joinFaces(s.trait, s.wildKept()))
+	if !s.extra {
+		dice += fmt.Sprintf(" d6:%s", joinFaces(s.wild, !s.wildKept()))
+	}
+
+	return RollRow{
+		nick:    strings.ToLower(nick),
+		channel: strings.ToLower(channel),
+		expr:    expr,
+		label:   s.label,
+		dice:    dice,
+		total:   s.total(),
+		time:    time.Now().UTC(),
+	}
+}
+
+// joinFaces lists faces for the roll log, in parentheses if dropped
+func joinFaces(dice []int, dropped bool) string {
+	out := make([]string, 0, len(dice))
+	for _, d := range dice {
+		if dropped {
+			out = append(out, fmt.Sprintf("(%d)", d))
+			continue
+		}
+		out = append(out, strconv.Itoa(d))
+	}
+	return strings.Join(out, ",")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var savageCases = []struct {
+	args    string
+	faces   []int
+	want    string
+	dice    string
+	wantErr bool
+}{
+	{args: "d8", faces: []int{5, 3}, want: "gbmor rolls d8: trait 5, wild (3) = 5 vs 4,  success", dice: "d8:5 d6:(3)"},
+	{args: "d8+1 shooting", faces: []int{8, 5, 3}, want: "gbmor rolls shooting (d8+1): trait 8! 5, wild (3), +1 = 14 vs 4,  success with 2 raises", dice: "d8:8,5 d6:(3)"},
+	{args: "d4 tn 6", faces: []int{2, 6, 6, 1}, want: "gbmor rolls d4: trait (2), wild 6! 6! 1 = 13 vs 6,  success with a raise", dice: "d4:(2) d6:6,6,1"},
+	{args: "d6 -2 tn5", faces: []int{4, 2}, want: "gbmor rolls d6-2: trait 4, wild (2), -2 = 2 vs 5,  failure", dice: "d6:4 d6:(2)"},
+	{args: "d10 # notice", faces: []int{1, 1}, want: "gbmor rolls notice (d10): trait 1, wild (1) = 1 vs 4,  critical failure!", dice: "d10:1 d6:(1)"},
+	{args: "d12 extra vs 8", faces: []int{12, 4}, want: "gbmor rolls d12: trait 12! 4 = 16 vs 8,  success with 2 raises", dice: "d12:12,4"},
+	{args: "d6 extra", faces: []int{1}, want: "gbmor rolls d6: trait 1 = 1 vs 4,  failure", dice: "d6:1"},
+	{args: "shooting", wantErr: true},
+	{args: "d7", wantErr: true},
+	{args: "d8 tn", wantErr: true},
+	{args: "d8 tn x", wantErr: true},
+}
+
+func Test_SavageRoll(t *testing.T) {
+	for _, tt := range savageCases {
+		t.Run(tt.args, func(t *testing.T) {
+			s, err := parseSavage(strings.Fields(tt.args))
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Got unexpected error: %s", err.Error())
+			}
+			if err == nil && tt.wantErr {
+				t.Fatalf("Expected error, got %v", s)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			s.roll(fixedRoller(tt.faces...))
+			if got := s.report("gbmor", false); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+			if got := s.historyRow("gbmor", "#deadlands").dice; got != tt.dice {
+				t.Errorf("Expected dice %s, got %s", tt.dice, got)
+			}
+		})
+	}
+
+	s, _ := parseSavage([]string{"d8+1", "tn", "6", "extra"})
+	if expr := s.historyRow("gbmor", "#deadlands").expr; expr != "sw d8+1 tn 6 extra" {
+		t.Errorf("Unexpected expression: %s", expr)
+	}
+}
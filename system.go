@@ -0,0 +1,42 @@
+package main
+
+// SystemRoll is a roll made by one game system's rules, reporting
+// its outcome in that system's terms rather than as a bare total
+type SystemRoll interface {
+	roll(r *Roller)
+	report(nick string, format bool) string
+	historyRow(nick, channel string) RollRow
+}
+
+// SheetRoll is a SystemRoll that can fill in what it
+// wasn't given from the roller's character sheet
+type SheetRoll interface {
+	useSheet(pc *PCRow)
+}
+
+// parseSystemRoll reads the arguments to one of the game system
+// commands, returning nil if cmd isn't one
+func parseSystemRoll(cmd string, args []string) (SystemRoll, error) {
+	var out SystemRoll
+	var err error
+
+	switch cmd {
+	case "!coc", "!skill":
+		out, err = parseCoc(args)
+	case "!pbta":
+		out, err = parsePbta(args)
+	case "!blades":
+		out, err = parseBlades(args)
+	case "!ironsworn":
+		out, err = parseIronsworn(args)
+	case "!sw":
+		out, err = parseSavage(args)
+	case "!nd":
+		out, err = parseNarrative(args)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}